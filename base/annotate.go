@@ -0,0 +1,257 @@
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const spdxTag = "SPDX-License-Identifier:"
+
+var (
+	// lineComments maps file extensions, and the names of files
+	// without one, to the line comment syntax of their language.
+	// Files of other kinds are not annotated.
+	lineComments = map[string]string{
+		".c": "//", ".h": "//", ".cc": "//", ".cpp": "//", ".hpp": "//",
+		".cs": "//", ".dart": "//", ".go": "//", ".java": "//",
+		".js": "//", ".jsx": "//", ".kt": "//", ".proto": "//",
+		".rs": "//", ".scala": "//", ".swift": "//", ".ts": "//", ".tsx": "//",
+		".bash": "#", ".ex": "#", ".exs": "#", ".pl": "#", ".py": "#",
+		".r": "#", ".rb": "#", ".sh": "#", ".toml": "#", ".yaml": "#", ".yml": "#",
+		".hs": "--", ".lua": "--", ".sql": "--",
+		"Dockerfile": "#", "Makefile": "#",
+	}
+
+	// annotateSkipDirs are directories of other people's code.
+	annotateSkipDirs = map[string]bool{"node_modules": true, "vendor": true}
+
+	// preambleRx matches lines that must stay at the top of a file:
+	// interpreter lines and Python encoding declarations.
+	preambleRx = regexp.MustCompile(`^(#!|#.*coding[:=])`)
+)
+
+// lineComment returns the line comment syntax for the file,
+// or an empty string if its kind is unknown.
+func lineComment(p string) string {
+	if c, ok := lineComments[filepath.Base(p)]; ok {
+		return c
+	}
+	return lineComments[strings.ToLower(filepath.Ext(p))]
+}
+
+// licenseExpression returns the SPDX license expression for the license
+// files in dir, such as "MIT OR Apache-2.0" for a dual licensed Rust crate,
+// or an empty string if dir has no license file that can be identified.
+func licenseExpression(s *settings, dir string, o *classifyOption) (string, error) {
+	candidates := licenseFilenames
+	if matches, err := filepath.Glob(filepath.Join(dir, defaultLicenseFilename+"-*")); err == nil {
+		for _, m := range matches {
+			candidates = append(candidates, filepath.Base(m))
+		}
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+
+	for _, name := range candidates {
+		text, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		c, err := classify(s, string(text), o.Strictness)
+		if err != nil {
+			return "", newErrReadFailed()
+		}
+
+		if c == nil || c.SpdxID == "" || c.Confidence < o.MinConfidence {
+			continue
+		}
+
+		// a tag should not use a deprecated identifier
+		id := currentSpdxID(c.SpdxID)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return strings.Join(ids, " OR "), nil
+}
+
+// addSPDXTag returns the contents with an SPDX-License-Identifier line
+// inserted at the top, below any lines that must come first. A blank
+// line separates the tag from the rest, so that it does not become
+// part of a doc comment.
+func addSPDXTag(contents []byte, comment, expr string) []byte {
+	var head []byte
+	rest := contents
+
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 || !preambleRx.Match(rest[:end]) {
+			break
+		}
+		head, rest = append(head, rest[:end+1]...), rest[end+1:]
+	}
+
+	var b bytes.Buffer
+	b.Write(head)
+	fmt.Fprintf(&b, "%s %s %s\n\n", comment, spdxTag, expr)
+	b.Write(rest)
+	return b.Bytes()
+}
+
+// isGenerated returns true if the contents are marked as generated,
+// following the Go convention that most generators use: a comment
+// saying "DO NOT EDIT" anywhere before the first line of code.
+func isGenerated(contents []byte, comment string) bool {
+	for _, line := range bytes.Split(contents, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || preambleRx.Match(line) {
+			continue
+		}
+		if !bytes.HasPrefix(line, []byte(comment)) && !bytes.HasPrefix(line, []byte("/*")) && !bytes.HasPrefix(line, []byte("*")) {
+			return false
+		}
+		if bytes.Contains(line, []byte("DO NOT EDIT")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Annotate walks the project in the current directory, or the directory
+// named in the arguments, and adds an SPDX-License-Identifier line to each
+// source file that does not have one. A file's license is that of the
+// nearest enclosing directory with a license file, so subdirectories
+// under a different license keep their own.
+func Annotate(args []string) error {
+	flagSet := simpleflag.NewFlagSet("annotate")
+	flagSet.Add("dry-run", []string{"--dry-run", "-dry-run"}, true)
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	root := "."
+	switch len(result.Remaining) {
+	case 0:
+	case 1:
+		root = result.Remaining[0]
+	default:
+		return newErrExpectedDirectory()
+	}
+
+	_, dryRun := result.Values["dry-run"]
+	_, asJSON := result.Values["json"]
+
+	summary := newBulkSummary("annotate", "checked", "annotated")
+	if dryRun {
+		summary.changed = "to annotate"
+	}
+
+	o, err := parseClassifyOption(nil)
+	if err != nil {
+		return err
+	}
+
+	s := defaultSettings()
+	rootExpr, err := licenseExpression(s, root, o)
+	if err != nil {
+		return err
+	}
+	if rootExpr == "" {
+		return newErrProjectLicenseNotFound(root)
+	}
+
+	// expressions of the directories visited so far;
+	// Walk visits a directory before its contents
+	exprs := map[string]string{filepath.Clean(root): rootExpr}
+
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return newErrReadInputFailed(p)
+		}
+
+		if info.IsDir() {
+			if p == root {
+				return nil
+			}
+			if strings.HasPrefix(info.Name(), ".") || annotateSkipDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			expr, err := licenseExpression(s, p, o)
+			if err != nil {
+				return err
+			}
+			if expr == "" {
+				expr = exprs[filepath.Dir(p)]
+			}
+			exprs[p] = expr
+			return nil
+		}
+
+		comment := lineComment(p)
+		if comment == "" {
+			return nil
+		}
+
+		contents, err := ioutil.ReadFile(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "license: failed to read %s\n", p)
+			summary.Failed++
+			return nil
+		}
+
+		if bytes.Contains(contents, []byte(spdxTag)) {
+			summary.OK++
+			return nil
+		}
+		if isGenerated(contents, comment) {
+			summary.Skipped++
+			return nil
+		}
+
+		expr := exprs[filepath.Dir(p)]
+		if !dryRun {
+			if err := writeFile(s, p, addSPDXTag(contents, comment, expr), info.Mode()); err != nil {
+				fmt.Fprintln(os.Stderr, newErrWriteFileFailed(p))
+				summary.Failed++
+				return nil
+			}
+		}
+
+		if !asJSON {
+			fmt.Printf("%s%-40s %s\n", indent, p, expr)
+		}
+		summary.Changed++
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := summary.print(asJSON); err != nil {
+		return err
+	}
+
+	if summary.Failed > 0 {
+		return newErrAnnotateFailed(summary.Failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package base
+
+import "testing"
+
+func TestAddSPDXTag(t *testing.T) {
+	testcases := []struct {
+		name     string
+		contents string
+		comment  string
+		want     string
+	}{
+		{
+			"go",
+			"// Package a does things.\npackage a\n",
+			"//",
+			"// SPDX-License-Identifier: MIT\n\n// Package a does things.\npackage a\n",
+		},
+		{
+			"interpreter line",
+			"#!/bin/sh\necho hi\n",
+			"#",
+			"#!/bin/sh\n# SPDX-License-Identifier: MIT\n\necho hi\n",
+		},
+		{
+			"interpreter line and encoding",
+			"#!/usr/bin/env python\n# -*- coding: utf-8 -*-\nimport os\n",
+			"#",
+			"#!/usr/bin/env python\n# -*- coding: utf-8 -*-\n# SPDX-License-Identifier: MIT\n\nimport os\n",
+		},
+		{
+			"empty",
+			"",
+			"--",
+			"-- SPDX-License-Identifier: MIT\n\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		if got := string(addSPDXTag([]byte(tc.contents), tc.comment, "MIT")); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	testcases := []struct {
+		name     string
+		contents string
+		comment  string
+		want     bool
+	}{
+		{"go generated", "// Code generated by stringer; DO NOT EDIT.\n\npackage a\n", "//", true},
+		{"after other comments", "// +build linux\n\n// Code generated by x. DO NOT EDIT.\npackage a\n", "//", true},
+		{"block comment", "/*\n * DO NOT EDIT\n */\nint x;\n", "//", true},
+		{"after interpreter line", "#!/usr/bin/env python\n# DO NOT EDIT\nimport os\n", "#", true},
+		{"after code", "package a\n\n// DO NOT EDIT\n", "//", false},
+		{"other comment syntax", "# DO NOT EDIT\npackage a\n", "//", false},
+		{"not generated", "// Package a does things.\npackage a\n", "//", false},
+		{"empty", "", "//", false},
+	}
+
+	for _, tc := range testcases {
+		if got := isGenerated([]byte(tc.contents), tc.comment); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
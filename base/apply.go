@@ -0,0 +1,452 @@
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// TargetsFile is the name of the manifest that lists
+// the files "license apply" renders for a project.
+const TargetsFile = "license.targets.yaml"
+
+// Formats of the files "license apply" renders.
+const (
+	formatText = "text"
+	formatGo   = "go"
+)
+
+var goIdentifierRx = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// targetsManifest is the contents of the targets file. Name, Year,
+// YearPolicy, Email, Org, and Copyright apply to every target that
+// does not set its own.
+type targetsManifest struct {
+	Name       string           `yaml:"name"`
+	Year       string           `yaml:"year"`
+	YearPolicy string           `yaml:"year-policy"`
+	Email      string           `yaml:"email"`
+	Org        string           `yaml:"org"`
+	Copyright  string           `yaml:"copyright"`
+	Targets    []manifestTarget `yaml:"targets"`
+}
+
+// manifestTarget is an output listed in the targets file:
+//
+//	output:   path of the file to write, relative to the targets file
+//	license:  license key, as for "license <license>"
+//	header:   true to render the license's header instead of its text
+//	template: path of a template file to render instead, such as a
+//	          NOTICE template; the license, if any, fills in its variables
+//	format:   "text" (the default), or "go" for a Go file that
+//	          declares the text as a constant
+//	package:  package of the Go file, by default the directory's name
+//	constant: name of the constant in the Go file, by default License
+//	year-policy: the year without a year, as with --year-policy
+//	copyright: the format of the copyright notice, as with --copyright
+type manifestTarget struct {
+	Output     string `yaml:"output"`
+	License    string `yaml:"license"`
+	Header     bool   `yaml:"header"`
+	Template   string `yaml:"template"`
+	Name       string `yaml:"name"`
+	Year       string `yaml:"year"`
+	YearPolicy string `yaml:"year-policy"`
+	Email      string `yaml:"email"`
+	Org        string `yaml:"org"`
+	Copyright  string `yaml:"copyright"`
+	Suffix     string `yaml:"suffix"`
+	Format     string `yaml:"format"`
+	Package    string `yaml:"package"`
+	Constant   string `yaml:"constant"`
+}
+
+// stagedOutput is a rendered output that has not been written yet.
+type stagedOutput struct {
+	Path     string
+	Contents []byte
+	Mode     os.FileMode
+	Existed  bool
+	Previous []byte
+}
+
+// readTargetsManifest reads and checks the targets file at p.
+func readTargetsManifest(p string) (*targetsManifest, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, newErrReadInputFailed(p)
+	}
+
+	var m targetsManifest
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, newErrInvalidTargets(p, err.Error())
+	}
+
+	if len(m.Targets) == 0 {
+		return nil, newErrInvalidTargets(p, "it lists no targets")
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range m.Targets {
+		where := fmt.Sprintf("target %d", i+1)
+		switch {
+		case t.Output == "":
+			return nil, newErrInvalidTargets(p, where+" has no output")
+		case seen[filepath.Clean(t.Output)]:
+			return nil, newErrInvalidTargets(p, where+" writes "+t.Output+" again")
+		case t.License == "" && t.Template == "":
+			return nil, newErrInvalidTargets(p, where+" has neither a license nor a template")
+		case t.Header && t.Template != "":
+			return nil, newErrInvalidTargets(p, where+" has both a template and header")
+		case t.Format != "" && t.Format != formatText && t.Format != formatGo:
+			return nil, newErrInvalidTargets(p, where+" has unknown format "+strconv.Quote(t.Format))
+		case t.Package != "" && !goIdentifierRx.MatchString(t.Package):
+			return nil, newErrInvalidTargets(p, where+" has invalid package "+strconv.Quote(t.Package))
+		case t.Constant != "" && !goIdentifierRx.MatchString(t.Constant):
+			return nil, newErrInvalidTargets(p, where+" has invalid constant "+strconv.Quote(t.Constant))
+		}
+		seen[filepath.Clean(t.Output)] = true
+	}
+
+	return &m, nil
+}
+
+// goSource returns the source of a Go file that declares text as a
+// string constant, so that a program can embed its own license.
+func goSource(pkg, constant, name string, text []byte) []byte {
+	literal := "`" + string(text) + "`"
+	if bytes.ContainsAny(text, "`\r") {
+		literal = strconv.Quote(string(text))
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"license apply\". DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "// %s is the text of the %s.\n", constant, name)
+	fmt.Fprintf(&b, "const %s = %s\n", constant, literal)
+	return b.Bytes()
+}
+
+// licenseSetting is like getLicenseSetting, for targets without a license.
+func licenseSetting(key, setting string) string {
+	if key == "" {
+		return ""
+	}
+	return getLicenseSetting(key, setting)
+}
+
+// firstNonEmpty returns the first of the values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// stageTargets renders every target of the manifest in dir into memory.
+// Nothing is written, so that a failure leaves the project unchanged.
+func stageTargets(s *settings, dir string, m *targetsManifest) ([]*stagedOutput, error) {
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return nil, newErrReadFailed()
+	}
+
+	// looking up the user's name runs git, so only do it once
+	var defaultName string
+	getDefaultName := func() string {
+		if defaultName == "" {
+			defaultName = getName()
+		}
+		return defaultName
+	}
+
+	targets := make([]Target, len(m.Targets))
+	selected := make([]License, len(m.Targets))
+	buffers := make([]*bytes.Buffer, len(m.Targets))
+
+	for i, mt := range m.Targets {
+		t := &targets[i]
+
+		if mt.License != "" {
+			l, ok := findLicense(licenses, mt.License)
+			if !ok {
+				return nil, newErrCannotFindLicense()
+			}
+			if err := ensureFetched(s, &l); err != nil {
+				return nil, err
+			}
+			t.Key, selected[i] = l.Key, l.withFullInfo(s)
+		}
+
+		if mt.Template != "" {
+			t.Template = filepath.Join(dir, mt.Template)
+			if !pathExists(t.Template) {
+				return nil, newErrReadInputFailed(t.Template)
+			}
+		}
+		t.Header = mt.Header
+
+		// prefer the target, then the manifest, then
+		// the settings for the license, then defaults
+		t.Name = firstNonEmpty(mt.Name, m.Name, licenseSetting(t.Key, "name"))
+		if t.Name == "" {
+			t.Name = getDefaultName()
+		}
+		t.Year = firstNonEmpty(mt.Year, m.Year, licenseSetting(t.Key, "year"))
+		if t.Year == "" {
+			policy, err := yearPolicy(t.Key, firstNonEmpty(mt.YearPolicy, m.YearPolicy), TargetsFile)
+			if err != nil {
+				return nil, err
+			}
+			output := filepath.Join(dir, mt.Output)
+			t.Year = policyYear(policy, append([]string{output}, licenseFilesIn(filepath.Dir(output))...))
+			t.YearPolicy = policy
+		}
+
+		format, err := copyrightFormat(t.Key, firstNonEmpty(mt.Copyright, m.Copyright), TargetsFile)
+		if err != nil {
+			return nil, err
+		}
+		t.Copyright = format
+		t.Org = firstNonEmpty(mt.Org, m.Org, licenseSetting(t.Key, "org"))
+		t.Email = firstNonEmpty(mt.Email, m.Email, licenseSetting(t.Key, "email"))
+		if t.Email == "" && format != "" {
+			t.Email = getEmail()
+		}
+
+		if v := firstNonEmpty(mt.Suffix, licenseSetting(t.Key, "suffix"), getSuffix()); v != "" {
+			t.Suffix = []string{v}
+		}
+
+		buffers[i] = new(bytes.Buffer)
+		t.Writer = buffers[i]
+	}
+
+	if err := renderAll(s, targets); err != nil {
+		return nil, err
+	}
+
+	var staged []*stagedOutput
+	for i, mt := range m.Targets {
+		o := &stagedOutput{Path: filepath.Join(dir, mt.Output), Contents: buffers[i].Bytes(), Mode: 0644}
+
+		if mt.Format == formatGo {
+			pkg, constant := mt.Package, mt.Constant
+			if pkg == "" {
+				abs, err := filepath.Abs(filepath.Dir(o.Path))
+				if err != nil || !goIdentifierRx.MatchString(filepath.Base(abs)) {
+					return nil, newErrInvalidTargets(mt.Output, "set a package for the Go file")
+				}
+				pkg = filepath.Base(abs)
+			}
+			if constant == "" {
+				constant = "License"
+			}
+			name := selected[i].Name
+			if name == "" {
+				name = filepath.Base(mt.Template)
+			}
+			o.Contents = goSource(pkg, constant, name, o.Contents)
+		}
+
+		// outputs only go in directories that exist, so that
+		// a rollback does not leave directories behind
+		if info, err := os.Stat(filepath.Dir(o.Path)); err != nil || !info.IsDir() {
+			return nil, newErrReadInputFailed(filepath.Dir(o.Path))
+		}
+
+		if info, err := os.Stat(o.Path); err == nil {
+			previous, err := ioutil.ReadFile(o.Path)
+			if err != nil {
+				return nil, newErrReadInputFailed(o.Path)
+			}
+			o.Existed, o.Previous, o.Mode = true, previous, info.Mode()
+		}
+
+		staged = append(staged, o)
+	}
+
+	return staged, nil
+}
+
+// commitOutputs writes the staged outputs all or nothing. Each output is
+// first written to a temporary file next to it, then the temporary files
+// are renamed over the outputs. If anything fails, the outputs already
+// replaced get their previous contents back, and the temporary files
+// are removed.
+func commitOutputs(s *settings, staged []*stagedOutput) error {
+	temps := make([]string, len(staged))
+
+	cleanup := func() {
+		for _, t := range temps {
+			if t != "" {
+				os.Remove(t)
+			}
+		}
+	}
+
+	for i, o := range staged {
+		f, err := ioutil.TempFile(filepath.Dir(o.Path), "."+filepath.Base(o.Path)+".")
+		if err == nil {
+			temps[i] = f.Name()
+			_, err = f.Write(o.Contents)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err == nil {
+			err = os.Chmod(temps[i], o.Mode)
+		}
+		if err != nil {
+			cleanup()
+			return newErrWriteFileFailed(o.Path)
+		}
+	}
+
+	// keep copies for undo before anything is replaced
+	befores := make([]string, len(staged))
+	for i, o := range staged {
+		befores[i] = snapshot(o.Path)
+	}
+
+	for i, o := range staged {
+		if err := os.Rename(temps[i], o.Path); err != nil {
+			rollbackOutputs(staged[:i])
+			cleanup()
+			return newErrWriteFileFailed(o.Path)
+		}
+		temps[i] = ""
+	}
+
+	for i, o := range staged {
+		recordChange(s, o.Path, befores[i], sha256Hex(o.Contents))
+	}
+
+	return nil
+}
+
+// rollbackOutputs restores the previous state of outputs that were
+// already replaced. Failures are reported, since there is nothing
+// more that can be done about them.
+func rollbackOutputs(replaced []*stagedOutput) {
+	for _, s := range replaced {
+		var err error
+		if s.Existed {
+			err = ioutil.WriteFile(s.Path, s.Previous, s.Mode)
+		} else {
+			err = os.Remove(s.Path)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "license: failed to restore %s\n", s.Path)
+		}
+	}
+}
+
+// printRenderBench prints the throughput of rendering the staged
+// outputs, for tuning large targets files. It goes to stderr, so
+// that it does not mix with the JSON output.
+func printRenderBench(staged []*stagedOutput, elapsed time.Duration) {
+	size := 0
+	for _, o := range staged {
+		size += len(o.Contents)
+	}
+
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = time.Nanosecond.Seconds()
+	}
+
+	fmt.Fprintf(os.Stderr, "rendered %d outputs, %d bytes, in %v: %.0f outputs/s, %.0f bytes/s\n",
+		len(staged), size, elapsed.Round(time.Microsecond), float64(len(staged))/seconds, float64(size)/seconds)
+}
+
+// Apply renders every output listed in the targets file, license.targets.yaml
+// in the current directory or the file named in the arguments, in one pass.
+// Either all outputs are written, or, if anything fails, none are.
+// With --dry-run, the outputs that would change are listed instead.
+// With --bench, how fast the outputs were rendered is printed to stderr.
+func Apply(args []string) error {
+	flagSet := simpleflag.NewFlagSet("apply")
+	flagSet.Add("dry-run", []string{"--dry-run", "-dry-run"}, true)
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	flagSet.Add("bench", []string{"--bench", "-bench"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	p := TargetsFile
+	switch len(result.Remaining) {
+	case 0:
+	case 1:
+		p = result.Remaining[0]
+	default:
+		return newErrExpectedFilename()
+	}
+
+	m, err := readTargetsManifest(p)
+	if err != nil {
+		return err
+	}
+
+	s := defaultSettings()
+	start := time.Now()
+	staged, err := stageTargets(s, filepath.Dir(p), m)
+	if err != nil {
+		return err
+	}
+
+	if _, bench := result.Values["bench"]; bench {
+		printRenderBench(staged, time.Since(start))
+	}
+
+	_, dryRun := result.Values["dry-run"]
+	_, asJSON := result.Values["json"]
+
+	summary := newBulkSummary("apply", "applied", "written")
+	if dryRun {
+		summary.verb, summary.changed = "checked", "to write"
+	}
+
+	var changed []*stagedOutput
+	for _, o := range staged {
+		state := "unchanged"
+		switch {
+		case !o.Existed:
+			state = "create"
+		case !bytes.Equal(o.Previous, o.Contents):
+			state = "update"
+		}
+		if state != "unchanged" {
+			changed = append(changed, o)
+		}
+		if !asJSON {
+			fmt.Printf("%s%-10s%s\n", indent, state, o.Path)
+		}
+	}
+
+	if !dryRun && len(changed) > 0 {
+		if err := commitOutputs(s, changed); err != nil {
+			return err
+		}
+	}
+
+	// outputs are written all or nothing, so none failed
+	// if this is reached
+	summary.OK, summary.Changed = len(staged)-len(changed), len(changed)
+	return summary.print(asJSON)
+}
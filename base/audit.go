@@ -0,0 +1,109 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Operations recorded in the audit log.
+const (
+	auditCreate = "create"
+	auditModify = "modify"
+	auditDelete = "delete"
+)
+
+// auditEntry is a line of the audit log, describing
+// a change the tool made to a file.
+type auditEntry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Op      string    `json:"op"`
+	Path    string    `json:"path"`
+	Before  string    `json:"sha256_before,omitempty"`
+	After   string    `json:"sha256_after,omitempty"`
+}
+
+// newAuditEntry returns the entry for a change to the file at p.
+// before and after are SHA-256 hashes of the contents, empty when
+// the file did not exist before or after the change.
+func newAuditEntry(p, before, after string) *auditEntry {
+	e := &auditEntry{
+		Time:    time.Now().UTC(),
+		Command: strings.Join(append([]string{"license"}, os.Args[1:]...), " "),
+		Op:      auditModify,
+		Path:    p,
+		Before:  before,
+		After:   after,
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		e.Path = abs
+	}
+	switch {
+	case before == "":
+		e.Op = auditCreate
+	case after == "":
+		e.Op = auditDelete
+	}
+	return e
+}
+
+// auditChange appends a change to the file at p to the audit log,
+// if one is configured. Failing to record a change does not undo
+// it, so it is only reported.
+func auditChange(p, before, after string) {
+	logPath := getAuditLog()
+	if logPath == "" || before == after {
+		return
+	}
+
+	b, err := json.Marshal(newAuditEntry(p, before, after))
+	if err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			_, err = f.Write(append(b, '\n'))
+			f.Close()
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "license: failed to record change to %s in audit log %s\n", p, logPath)
+	}
+}
+
+// recordChange records a change to the file at p in the audit log,
+// and in the undo journal so that it can be reverted with undo.
+func recordChange(s *settings, p, before, after string) {
+	auditChange(p, before, after)
+	journalChange(s, p, before, after)
+}
+
+// writeFile is like ioutil.WriteFile, and records the change
+// in the audit log and the undo journal.
+func writeFile(s *settings, p string, data []byte, mode os.FileMode) error {
+	before := snapshot(p)
+
+	if err := ioutil.WriteFile(p, data, mode); err != nil {
+		return err
+	}
+
+	recordChange(s, p, before, sha256Hex(data))
+	return nil
+}
+
+// removeFile is like os.Remove, and records the change
+// in the audit log and the undo journal.
+func removeFile(s *settings, p string) error {
+	before := snapshot(p)
+
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+
+	recordChange(s, p, before, "")
+	return nil
+}
@@ -1,7 +1,10 @@
 package base
 
 import (
-	"github.com/mitchellh/go-homedir"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/nishanths/license/logger"
 	"github.com/termie/go-shutil"
 	"gopkg.in/nishanths/simpleflag.v1"
@@ -9,21 +12,89 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 )
 
-func setLogLevel(args []string) error {
+// BootstrapSummary describes what a call to Bootstrap did
+// to the local licenses.
+type BootstrapSummary struct {
+	Added     []string       `json:"added"`
+	Updated   []string       `json:"updated"`
+	Unchanged []string       `json:"unchanged"`
+	Failed    []string       `json:"failed"`
+	Stale     []string       `json:"stale"`
+	Removed   []string       `json:"removed"`
+	Source    string         `json:"source"`
+	Bytes     int64          `json:"bytes_downloaded"`
+	Elapsed   time.Duration  `json:"elapsed_ns"`
+	Licenses  []FetchMetrics `json:"licenses"`
+}
+
+func newBootstrapSummary() *BootstrapSummary {
+	return &BootstrapSummary{
+		Added:     []string{},
+		Updated:   []string{},
+		Unchanged: []string{},
+		Failed:    []string{},
+		Stale:     []string{},
+		Removed:   []string{},
+		Licenses:  []FetchMetrics{},
+	}
+}
+
+func (s *BootstrapSummary) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d unchanged, %d failed, %d stale, %d removed (%d bytes in %v)",
+		len(s.Added), len(s.Updated), len(s.Unchanged), len(s.Failed),
+		len(s.Stale), len(s.Removed), s.Bytes, s.Elapsed)
+}
+
+// sort sorts each list of license keys in the summary.
+func (s *BootstrapSummary) sort() {
+	for _, keys := range [][]string{s.Added, s.Updated, s.Unchanged, s.Failed, s.Stale, s.Removed} {
+		sort.Strings(keys)
+	}
+	sort.Slice(s.Licenses, func(i, j int) bool {
+		return s.Licenses[i].Key < s.Licenses[j].Key
+	})
+}
+
+// kept records that the license was kept from the local copy.
+func (s *BootstrapSummary) kept(key string) {
+	s.Unchanged = append(s.Unchanged, key)
+	s.Licenses = append(s.Licenses, FetchMetrics{Key: key, CacheHit: true})
+}
+
+// bootstrapOption holds the options for Bootstrap, and what the
+// command line does with its result.
+type bootstrapOption struct {
+	JSON     bool
+	Minimal  bool
+	Prefetch bool
+	Options  []Option
+}
+
+// parseBootstrapArgs sets the log level from the arguments
+// and returns the remaining bootstrap options.
+func parseBootstrapArgs(args []string) (*bootstrapOption, error) {
 	flagSet := simpleflag.NewFlagSet("")
 	flagSet.Add("quiet", []string{"--quiet", "-quiet", "-q"}, true)
 	flagSet.Add("verbose", []string{"--verbose", "-verbose", "-v"}, true)
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	flagSet.Add("repair", []string{"--repair", "-repair"}, true)
+	flagSet.Add("progress", []string{"--progress", "-progress"}, false)
+	flagSet.Add("prune", []string{"--prune", "-prune"}, true)
+	flagSet.Add("minimal", []string{"--minimal", "-minimal"}, true)
+	flagSet.Add("prefetch", []string{"--prefetch", "-prefetch"}, true)
 	result, err := flagSet.Parse(args)
 
 	if err != nil {
-		return newErrParsingArguments()
+		return nil, newErrParsingArguments()
 	}
 
 	if len(result.BadFlags) > 0 {
-		return newErrBadFlagSyntax(result.BadFlags[0])
+		return nil, newErrBadFlagSyntax(result.BadFlags[0])
 	}
 
 	if _, exists := result.Values["quiet"]; exists {
@@ -34,26 +105,66 @@ func setLogLevel(args []string) error {
 		logger.SetVerbose(true)
 	}
 
-	return nil
+	o := &bootstrapOption{}
+	_, o.JSON = result.Values["json"]
+	_, o.Minimal = result.Values["minimal"]
+	_, o.Prefetch = result.Values["prefetch"]
+
+	if o.Minimal {
+		o.Options = append(o.Options, WithMinimal())
+	}
+	if _, exists := result.Values["repair"]; exists {
+		o.Options = append(o.Options, WithRepair())
+	}
+	if _, exists := result.Values["prune"]; exists {
+		o.Options = append(o.Options, WithPrune())
+	}
+
+	if format, exists := result.Values["progress"]; exists {
+		if format != progressFormatJSON {
+			return nil, newErrUnknownArgument("--progress", format)
+		}
+		o.Options = append(o.Options, WithProgress(os.Stderr))
+	}
+
+	return o, nil
 }
 
-func writeLicense(l *License, rawPath, templatesPath string) error {
+// writeLicense fetches the full information for a license and writes it,
+// along with its template, to disk. It returns the fetched JSON.
+func writeLicense(ctx context.Context, s *settings, l *License, rawPath, templatesPath string) ([]byte, error) {
+	// the key names the files
+	if !licenseKeyRx.MatchString(l.Key) {
+		return nil, newErrInvalidLicenseKey(l.Key)
+	}
+
 	// fetch full license info JSON
-	content, err := l.fetchFullInfo()
+	metrics, start := fetchMetricsFrom(ctx), time.Now()
+	content, err := l.fetchFullInfo(ctx, s)
+	metrics.fetched(start, len(content))
 	if err != nil {
-		return newErrFetchFailed()
+		return nil, newErrFetchFailed()
 	}
+	defer metrics.wrote(time.Now())
 
 	// deserialize JSON to License struct
 	fullLicense, err := jsonToLicense(content)
 	if err != nil {
-		return newErrDeserializeFailed(content)
+		return content, newErrDeserializeFailed(content)
+	}
+
+	// store the JSON in a stable form so that identical
+	// upstream data always produces identical files
+	canonical, err := canonicalJSON(content)
+	if err != nil {
+		return content, newErrDeserializeFailed(content)
 	}
+	content = canonical
 
 	// write JSON to disk
 	rawFilePath := filepath.Join(rawPath, l.Key+".json")
 	if err := ioutil.WriteFile(rawFilePath, content, perm); err != nil {
-		return newErrWriteFileFailed(rawFilePath)
+		return content, newErrWriteFileFailed(rawFilePath)
 	}
 
 	// construct template and save template in templates directory
@@ -61,29 +172,318 @@ func writeLicense(l *License, rawPath, templatesPath string) error {
 
 	templateFilePath := filepath.Join(templatesPath, l.Key+".tmpl")
 	if err := ioutil.WriteFile(templateFilePath, []byte(templateData), perm); err != nil {
-		return newErrWriteFileFailed(templateFilePath)
+		return content, newErrWriteFileFailed(templateFilePath)
 	}
 
-	return nil
+	if err := writeHeaderTemplate(&fullLicense, templatesPath); err != nil {
+		return content, err
+	}
+
+	return content, nil
+}
+
+// UpdateCommand is "license update": it parses the arguments into
+// options for Bootstrap, and reports what Bootstrap did.
+func UpdateCommand(args []string) error {
+	o, err := parseBootstrapArgs(args)
+	if err != nil {
+		return err
+	}
+
+	summary, err := Bootstrap(o.Options...)
+
+	if o.JSON {
+		if b, err := json.MarshalIndent(summary, "", indent); err == nil {
+			fmt.Println(string(b))
+		}
+	} else {
+		logger.VerbosePrintln(summary)
+		printFetchMetrics(summary.Licenses)
+	}
+
+	if n := sourceNotice(defaultSettings(), summary.Source); n != "" && !o.JSON {
+		logger.Println(n)
+	}
+
+	if o.Minimal && o.Prefetch && err == nil {
+		if err := startPrefetch(); err != nil {
+			logger.Println("license: failed to start prefetching licenses; run \"license prefetch\" to fetch them")
+		}
+	}
+
+	if len(summary.Stale) > 0 && !o.JSON {
+		logger.Printf("license: kept %d license(s) no longer available upstream: %v\n", len(summary.Stale), summary.Stale)
+		logger.Println("license: run \"license update --prune\" to remove them")
+	}
+
+	return err
 }
 
 // Bootstrap updates local licenses
-// to the latest online versions
-func Bootstrap(args []string) error {
-	if err := setLogLevel(args); err != nil {
+// to the latest online versions, and returns a summary
+// of the changes made.
+func Bootstrap(opts ...Option) (*BootstrapSummary, error) {
+	start := time.Now()
+
+	s, done := useOptions(opts)
+	defer done()
+
+	if s.Minimal && s.Repair {
+		return newBootstrapSummary(), newErrIncompatibleFlags("--minimal", "--repair")
+	}
+
+	var progress *progressReporter
+	if s.Progress != nil {
+		progress = newProgressReporter(s.Progress)
+	}
+
+	// cancel outstanding requests and clean up
+	// if the process is interrupted
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = withLogger(ctx, s.logger())
+
+	var summary *BootstrapSummary
+	var err error
+	if s.Repair {
+		summary, err = repair(ctx, s, progress)
+	} else {
+		summary, err = bootstrap(ctx, s, progress)
+	}
+	summary.Elapsed = time.Since(start)
+	summary.sort()
+
+	return summary, err
+}
+
+// setStoreModTimes sets the modification time of every file under root
+// to storeModTime, so the store does not depend on when it was written.
+func setStoreModTimes(root string) error {
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(p, storeModTime, storeModTime)
+	})
+}
+
+// normalizeStore brings a data directory that was written to in place
+// into the form an update leaves it in: files stored as objects, an
+// up-to-date manifest, and fixed modification times.
+func normalizeStore(p string) error {
+	if _, _, err := storeObjects(p); err != nil {
 		return err
 	}
+	if err := writeManifest(p); err != nil {
+		return err
+	}
+	if err := setStoreModTimes(p); err != nil {
+		return newErrWriteFileFailed(p)
+	}
+	return nil
+}
+
+// carryOverStale finds the local licenses that are no longer in the
+// upstream index. Unless pruning, it copies their files into dataPath and
+// adds them to the index there, so that they remain available.
+func carryOverStale(s *settings, dataPath string, upstream []License, prune bool, summary *BootstrapSummary) error {
+	localIndex, err := readIndex(s)
+	if err != nil {
+		return nil // nothing to carry over
+	}
+
+	var local []map[string]interface{}
+	if err := json.Unmarshal(localIndex, &local); err != nil {
+		return nil // a broken index has nothing worth keeping
+	}
+
+	available := make(map[string]bool)
+	for _, l := range upstream {
+		available[l.Key] = true
+	}
+
+	var stale []map[string]interface{}
+	for _, entry := range local {
+		key, _ := entry["key"].(string)
+		if key == "" || available[key] {
+			continue
+		}
+		if prune {
+			summary.Removed = append(summary.Removed, key)
+			continue
+		}
+		summary.Stale = append(summary.Stale, key)
+		stale = append(stale, entry)
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	for _, entry := range stale {
+		if _, err := copyLicenseFiles(s, dataPath, entry["key"].(string)); err != nil {
+			return err
+		}
+	}
+
+	indexFilePath := filepath.Join(dataPath, IndexFile)
+	index, err := ioutil.ReadFile(indexFilePath)
+	if err != nil {
+		return newErrReadFailed()
+	}
+
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(index, &entries); err != nil {
+		return newErrDeserializeFailed(index)
+	}
+
+	merged, err := json.Marshal(append(entries, stale...))
+	if err != nil {
+		return newErrSerializeFailed(stale)
+	}
+
+	canonical, err := canonicalIndexJSON(merged)
+	if err != nil {
+		return newErrDeserializeFailed(merged)
+	}
+
+	if err := ioutil.WriteFile(indexFilePath, canonical, perm); err != nil {
+		return newErrWriteFileFailed(indexFilePath)
+	}
+
+	return nil
+}
+
+// fetchLicenses fetches every license in the index and writes it to
+// the data directory being built, tallying the results in summary. It
+// returns a snapshot of the fetched catalog.
+func fetchLicenses(ctx context.Context, s *settings, licenses []License, rawPath, templatesPath string, progress *progressReporter, summary *BootstrapSummary) (*catalogSnapshot, error) {
+	type result struct {
+		Key      string
+		Existing []byte
+		Content  []byte
+		Metrics  *FetchMetrics
+		Err      error
+	}
+
+	progress.start("licenses", len(licenses))
+
+	var wg sync.WaitGroup
+	wg.Add(len(licenses))
+	ch := make(chan result, len(licenses))
+
+	// limit the fetches in flight, if asked to
+	var slots chan struct{}
+	if n := s.Concurrency; n > 0 {
+		slots = make(chan struct{}, n)
+	}
+
+	for _, l := range licenses {
+		me := l // self copy needed because we do not want to use the same `l` address that for ranges over
+
+		go func(l *License) {
+			defer wg.Done()
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			existing, _ := l.readFullInfo(s)
+			metrics := &FetchMetrics{Key: l.Key}
+			content, err := writeLicense(withFetchMetrics(ctx, metrics), s, l, rawPath, templatesPath)
+			progress.advance("licenses", l.Key)
+			ch <- result{l.Key, existing, content, metrics, err}
+		}(&me)
+	}
+
+	wg.Wait()
+	close(ch)
+
+	if ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+
+	// tally results and check for errors
+	var firstErr error
+	snapshot := newCatalogSnapshot()
+
+	for r := range ch {
+		summary.Bytes += int64(len(r.Content))
+		summary.Licenses = append(summary.Licenses, *r.Metrics)
+		if r.Err == nil {
+			snapshot.add(r.Key, r.Content)
+		}
+
+		switch {
+		case r.Err != nil:
+			summary.Failed = append(summary.Failed, r.Key)
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+		case r.Existing == nil:
+			summary.Added = append(summary.Added, r.Key)
+		case !bytes.Equal(r.Existing, r.Content):
+			summary.Updated = append(summary.Updated, r.Key)
+		default:
+			summary.Unchanged = append(summary.Unchanged, r.Key)
+		}
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return snapshot, nil
+}
+
+// keepFetchedLicenses copies the files of the licenses in the index
+// that were already fetched into the data directory being built.
+func keepFetchedLicenses(s *settings, dataPath string, licenses []License, summary *BootstrapSummary) error {
+	for _, l := range licenses {
+		kept, err := copyLicenseFiles(s, dataPath, l.Key)
+		if err != nil {
+			return err
+		}
+		if kept {
+			summary.kept(l.Key)
+		}
+	}
+	return nil
+}
+
+// copyLicenseFiles copies the local files of the license into the data
+// directory being built, and returns whether there were any.
+func copyLicenseFiles(s *settings, dataPath, key string) (bool, error) {
+	copied := false
+	for _, f := range []string{
+		filepath.Join(RawDirectory, key+".json"),
+		filepath.Join(TemplatesDirectory, key+".tmpl"),
+		filepath.Join(TemplatesDirectory, key+headerTemplateSuffix),
+	} {
+		contents, err := read(s, f)
+		if err != nil {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dataPath, f), contents, perm); err != nil {
+			return copied, newErrWriteFileFailed(filepath.Join(dataPath, f))
+		}
+		copied = true
+	}
+	return copied, nil
+}
+
+func bootstrap(ctx context.Context, s *settings, progress *progressReporter) (*BootstrapSummary, error) {
+	summary := newBootstrapSummary()
 
 	// bail immediately if we cannot find the user's home directory
-	home, err := homedir.Dir()
+	root, err := s.storePath()
 	if err != nil {
-		return newErrCannotLocateHomeDir()
+		return summary, newErrCannotLocateHomeDir()
 	}
 
 	// create temporary directory
 	tempLicensePath, err := ioutil.TempDir("", tempDirPrefix)
 	if err != nil {
-		return newErrCreateTempDirFailed(tempLicensePath)
+		return summary, newErrCreateTempDirFailed(tempLicensePath)
 	}
 
 	// make path strings relative to temp directory
@@ -102,72 +502,123 @@ func Bootstrap(args []string) error {
 
 	for _, p := range pathsToMake {
 		if err := os.MkdirAll(p, perm); err != nil {
-			return newErrCreateDirFailed(p)
+			return summary, newErrCreateDirFailed(p)
 		}
 	}
 
 	// fetch index file json
 	// return error if we failed to fetch
-	serialized, err := fetchIndex()
+	progress.start("index", 1)
+	serialized, source, err := fetchIndex(ctx, s)
+	if ctx.Err() != nil {
+		return summary, contextError(ctx)
+	}
 	if err != nil {
-		return newErrFetchFailed()
+		return summary, fetchError(err)
 	}
+	summary.Source = source
+	summary.Bytes += int64(len(serialized))
+	progress.advance("index", "")
 
-	logger.VerbosePrintln("fetched data from api.github.com...")
+	// store the index in a stable form
+	canonical, err := canonicalIndexJSON(serialized)
+	if err != nil {
+		return summary, newErrDeserializeFailed(serialized)
+	}
+	serialized = canonical
+
+	if err := checkIndex(serialized); err != nil {
+		return summary, err
+	}
+
+	loggerFrom(ctx).VerbosePrintf("fetched data from %s...\n", sourceName(source))
 
 	// write fetched index JSON to file
 	if err := ioutil.WriteFile(indexFilePath, serialized, perm); err != nil {
-		return newErrCreateDirFailed(indexFilePath)
+		return summary, newErrCreateDirFailed(indexFilePath)
 	}
 
-	logger.VerbosePrintln("created local index file...")
+	loggerFrom(ctx).VerbosePrintln("created local index file...")
 
 	// make list of short licenses
 	// from the fetched index file
 	licenses, err := jsonToList(serialized)
 
 	if err != nil {
-		return newErrDeserializeFailed(serialized)
+		return summary, newErrDeserializeFailed(serialized)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(licenses))
-	ch := make(chan error, len(licenses))
+	// a minimal update installs only the index, keeping the
+	// licenses already fetched; the others are fetched when used
+	var snapshot *catalogSnapshot
+	if s.Minimal {
+		err = keepFetchedLicenses(s, dataPath, licenses, summary)
+	} else {
+		snapshot, err = fetchLicenses(ctx, s, licenses, rawPath, templatesPath, progress, summary)
+	}
+	if err != nil {
+		return summary, err
+	}
 
-	for _, l := range licenses {
-		me := l // self copy needed because we do not want to use the same `l` address that for ranges over
+	loggerFrom(ctx).VerbosePrintln("created license templates...")
 
-		go func(l *License) {
-			defer wg.Done()
-			ch <- writeLicense(l, rawPath, templatesPath)
-		}(&me)
+	if err := carryOverStale(s, dataPath, licenses, s.Prune, summary); err != nil {
+		return summary, err
 	}
 
-	wg.Wait()
-	close(ch)
+	files, objects, err := storeObjects(dataPath)
+	if err != nil {
+		return summary, err
+	}
+	loggerFrom(ctx).VerbosePrintf("stored %d files as %d distinct texts...\n", files, objects)
 
-	// check for errors
-	for err := range ch {
-		if err != nil {
-			return err
-		}
+	if err := writeManifest(dataPath); err != nil {
+		return summary, err
 	}
 
-	logger.VerbosePrintln("created license templates...")
+	// remove exisiting path + data, leaving
+	// anything else in the license directory alone
+	progress.start("install", 1)
+	realDataPath := path.Join(root, DataDirectory)
 
-	// remove exisiting path + data
-	realLicensePath := path.Join(home, LicenseDirectory)
+	// a prefetch writes to the data directory that is about to be
+	// replaced; it can be started again after a minimal update
+	stopPrefetch(s)
 
-	if err := os.RemoveAll(realLicensePath); err != nil && os.IsPermission(err) {
-		return newErrRemovePathFailed(realLicensePath)
+	if err := os.RemoveAll(realDataPath); err != nil && os.IsPermission(err) {
+		return summary, newErrRemovePathFailed(realDataPath)
+	}
+
+	if err := os.MkdirAll(path.Dir(realDataPath), perm); err != nil {
+		return summary, newErrCreateDirFailed(path.Dir(realDataPath))
 	}
 
 	// copy temp data to real path
-	if err := shutil.CopyTree(tempLicensePath, realLicensePath, nil); err != nil {
-		return newErrCopyTreeFailed(tempLicensePath, realLicensePath)
+	if err := shutil.CopyTree(dataPath, realDataPath, nil); err != nil {
+		return summary, newErrCopyTreeFailed(dataPath, realDataPath)
+	}
+	forgetRefs()
+
+	if err := setStoreModTimes(realDataPath); err != nil {
+		return summary, newErrWriteFileFailed(realDataPath)
 	}
 
-	logger.VerbosePrintln("bootstrap complete!")
+	// the data is now in the current layout
+	if err := writeStoreVersion(path.Dir(realDataPath), storeVersion); err != nil {
+		return summary, err
+	}
 
-	return nil
+	progress.advance("install", "")
+
+	// keep a record of upstream for "license changes";
+	// a minimal update has too little to record
+	if snapshot != nil {
+		if err := recordSnapshot(s, snapshot); err != nil {
+			loggerFrom(ctx).VerbosePrintln(err)
+		}
+	}
+
+	loggerFrom(ctx).VerbosePrintln("bootstrap complete!")
+
+	return summary, nil
 }
@@ -0,0 +1,186 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"sort"
+	"time"
+)
+
+// defaultChangesInterval is how far back changes looks
+// when no date is given: a weekly digest.
+const defaultChangesInterval = 7 * 24 * time.Hour
+
+// catalogChange is a license that changed upstream, and the time of
+// the first recorded snapshot in which it was in its current state.
+type catalogChange struct {
+	Key       string    `json:"key"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// catalogChanges summarizes the changes to the upstream catalog between
+// the baseline snapshot, the last one recorded at or before Since, and now.
+type catalogChanges struct {
+	Since    time.Time       `json:"since"`
+	Baseline time.Time       `json:"baseline"`
+	Added    []catalogChange `json:"added"`
+	Removed  []catalogChange `json:"removed"`
+	Reworded []catalogChange `json:"reworded"`
+}
+
+// parseSince parses a date such as 2024-01-01, or a time in RFC 3339 format.
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// diffCatalog compares the current snapshot with the history, oldest first.
+func diffCatalog(history []catalogSnapshot, current *catalogSnapshot, since time.Time) *catalogChanges {
+	baseline := history[0]
+	for _, s := range history {
+		if s.Time.After(since) {
+			break
+		}
+		baseline = s
+	}
+
+	c := &catalogChanges{
+		Since:    since,
+		Baseline: baseline.Time,
+		Added:    []catalogChange{},
+		Removed:  []catalogChange{},
+		Reworded: []catalogChange{},
+	}
+
+	// the time each license was first seen in its current state
+	firstSeen := func(key string) time.Time {
+		want, wantOK := current.Licenses[key]
+		for _, s := range history {
+			if !s.Time.After(baseline.Time) {
+				continue
+			}
+			if got, ok := s.Licenses[key]; got == want && ok == wantOK {
+				return s.Time
+			}
+		}
+		return current.Time
+	}
+
+	for key, hash := range current.Licenses {
+		old, ok := baseline.Licenses[key]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, catalogChange{key, firstSeen(key)})
+		case old != hash:
+			c.Reworded = append(c.Reworded, catalogChange{key, firstSeen(key)})
+		}
+	}
+	for key := range baseline.Licenses {
+		if _, ok := current.Licenses[key]; !ok {
+			c.Removed = append(c.Removed, catalogChange{key, firstSeen(key)})
+		}
+	}
+
+	for _, list := range [][]catalogChange{c.Added, c.Removed, c.Reworded} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	}
+
+	return c
+}
+
+func (c *catalogChanges) print() {
+	const day = "2006-01-02"
+
+	fmt.Printf("Upstream license changes since %s", c.Since.Format(day))
+	if c.Baseline.After(c.Since) {
+		fmt.Printf(" (no record before %s)", c.Baseline.Format(day))
+	}
+	fmt.Print(":\n\n")
+
+	if len(c.Added)+len(c.Removed)+len(c.Reworded) == 0 {
+		fmt.Println(indent + "none")
+		return
+	}
+
+	for _, section := range []struct {
+		Title   string
+		Changes []catalogChange
+	}{
+		{"Added", c.Added},
+		{"Removed", c.Removed},
+		{"Reworded", c.Reworded},
+	} {
+		if len(section.Changes) == 0 {
+			continue
+		}
+		fmt.Println(section.Title + ":")
+		for _, change := range section.Changes {
+			fmt.Printf("%s%-14s(first seen %s)\n", indent, change.Key, change.FirstSeen.Format(day))
+		}
+	}
+}
+
+// Changes fetches the upstream catalog of licenses and summarizes which
+// licenses were added, removed, or reworded since a date, by comparing
+// it with the snapshots of the catalog recorded by each update.
+func Changes(args []string) error {
+	flagSet := simpleflag.NewFlagSet("changes")
+	flagSet.Add("since", []string{"--since", "-since"}, false)
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	since := time.Now().Add(-defaultChangesInterval)
+	if v, exists := result.Values["since"]; exists {
+		if since, err = parseSince(v); err != nil {
+			return newErrInvalidArgument("--since", v)
+		}
+	}
+
+	s := defaultSettings()
+	history, err := readHistory(s)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	current, err := fetchCatalog(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	// the fetched catalog is history for later runs
+	if err := recordSnapshot(s, current); err != nil {
+		return err
+	}
+
+	if len(history) == 0 {
+		return newErrNoCatalogHistory()
+	}
+
+	c := diffCatalog(history, current, since)
+
+	if _, exists := result.Values["json"]; exists {
+		b, err := json.MarshalIndent(c, "", indent)
+		if err != nil {
+			return newErrSerializeFailed(c)
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	c.print()
+	return nil
+}
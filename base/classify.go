@@ -0,0 +1,250 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultMinConfidence is the lowest confidence at which
+	// a text is considered to match a license.
+	defaultMinConfidence = 0.8
+
+	// strictnessLoose ignores case and punctuation when comparing texts.
+	strictnessLoose = "loose"
+	// strictnessStrict compares case and punctuation, ignoring only
+	// whitespace and copyright lines.
+	strictnessStrict = "strict"
+)
+
+var (
+	classifyWordRx       = regexp.MustCompile("[a-z0-9]+")
+	classifyStrictWordRx = regexp.MustCompile("[A-Za-z0-9]+|[^\\sA-Za-z0-9]")
+	classifyCopyrightRx  = regexp.MustCompile("(?im)^[ \t]*copyright\\b.*$")
+)
+
+type classifyOption struct {
+	MinConfidence float64
+	Strictness    string
+}
+
+// Classification is the license that best matches a text.
+type Classification struct {
+	Key        string  `json:"key"`
+	SpdxID     string  `json:"spdx_id"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+func (c *Classification) String() string {
+	return fmt.Sprintf("%s (%s) %.1f%%", c.Key, c.SpdxID, c.Confidence*100)
+}
+
+// classifyTokens returns the words of a text, ignoring whitespace and
+// copyright lines, which differ between copies of the same license.
+// With loose strictness, words are lowercased and punctuation is ignored.
+// With strict strictness, each punctuation character is a word of its own.
+func classifyTokens(text, strictness string) []string {
+	text = classifyCopyrightRx.ReplaceAllString(text, "")
+	if strictness == strictnessStrict {
+		return classifyStrictWordRx.FindAllString(text, -1)
+	}
+	return classifyWordRx.FindAllString(strings.ToLower(text), -1)
+}
+
+// bigrams returns the number of occurrences of each
+// pair of consecutive words.
+func bigrams(words []string) map[string]int {
+	m := make(map[string]int)
+	for i := 0; i+1 < len(words); i++ {
+		m[words[i]+" "+words[i+1]]++
+	}
+	return m
+}
+
+// similarity returns the Sørensen–Dice coefficient of two bigram
+// multisets: 1 for identical texts, 0 for texts with nothing in common.
+func similarity(a, b map[string]int) float64 {
+	var common, total int
+	for k, n := range a {
+		total += n
+		if m := b[k]; m < n {
+			common += m
+		} else {
+			common += n
+		}
+	}
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// classify returns the local license that best matches text,
+// or nil if there are no local licenses.
+func classify(s *settings, text, strictness string) (*Classification, error) {
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return nil, err
+	}
+
+	input := bigrams(classifyTokens(text, strictness))
+	var best *Classification
+
+	for _, l := range licenses {
+		content, err := l.readFullInfo(s)
+		if os.IsNotExist(err) {
+			continue // not fetched after a minimal update
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		full, err := jsonToLicense(content)
+		if err != nil {
+			return nil, err
+		}
+
+		c := similarity(input, bigrams(classifyTokens(full.Body, strictness)))
+		if best == nil || c > best.Confidence {
+			best = &Classification{full.Key, full.SpdxID, full.Name, c}
+		}
+	}
+
+	return best, nil
+}
+
+// parseClassifyOption determines the classification options,
+// preferring arguments to the environment, and the environment to defaults.
+func parseClassifyOption(values map[string]string) (*classifyOption, error) {
+	o := &classifyOption{
+		MinConfidence: defaultMinConfidence,
+		Strictness:    strictnessLoose,
+	}
+
+	confidence, exists := values["min-confidence"]
+	source := "--min-confidence"
+	if !exists {
+		confidence, source = os.Getenv(MinConfidenceEnvVariable), MinConfidenceEnvVariable
+	}
+	if confidence != "" {
+		c, err := strconv.ParseFloat(confidence, 64)
+		if err != nil || c < 0 || c > 1 {
+			return nil, newErrInvalidArgument(source, confidence)
+		}
+		o.MinConfidence = c
+	}
+
+	strictness, exists := values["strictness"]
+	source = "--strictness"
+	if !exists {
+		strictness, source = os.Getenv(StrictnessEnvVariable), StrictnessEnvVariable
+	}
+	switch strictness {
+	case "":
+	case strictnessLoose, strictnessStrict:
+		o.Strictness = strictness
+	default:
+		return nil, newErrInvalidArgument(source, strictness)
+	}
+
+	return o, nil
+}
+
+// is returns true if arg names the classified license
+// by key, SPDX identifier, or name, ignoring case.
+func (c *Classification) is(arg string) bool {
+	return strings.EqualFold(arg, c.Key) || strings.EqualFold(arg, c.SpdxID) || strings.EqualFold(arg, c.Name)
+}
+
+// Classify identifies the license of the text in the file named by
+// the arguments, or of standard input if the file is "-", and prints
+// the best-matching license.
+//
+// With --check, Classify returns an error unless the text is the given
+// license. With --quiet, nothing at all is printed, so that the result
+// is conveyed only by the exit code.
+func Classify(args []string) error {
+	quiet, err := classifyCommand(args)
+	if quiet {
+		return silence(err)
+	}
+	return err
+}
+
+func classifyCommand(args []string) (quiet bool, err error) {
+	flagSet := simpleflag.NewFlagSet("classify")
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	flagSet.Add("min-confidence", []string{"--min-confidence", "-min-confidence"}, false)
+	flagSet.Add("strictness", []string{"--strictness", "-strictness"}, false)
+	flagSet.Add("check", []string{"--check", "-check"}, false)
+	flagSet.Add("quiet", []string{"--quiet", "-quiet", "-q"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return false, newErrParsingArguments()
+	}
+
+	_, quiet = result.Values["quiet"]
+
+	if len(result.BadFlags) > 0 {
+		return quiet, newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	o, err := parseClassifyOption(result.Values)
+	if err != nil {
+		return quiet, err
+	}
+
+	if len(result.Remaining) != 1 {
+		return quiet, newErrExpectedFilename()
+	}
+
+	var text []byte
+	if f := result.Remaining[0]; f == "-" {
+		text, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		text, err = ioutil.ReadFile(f)
+	}
+	if err != nil {
+		return quiet, newErrReadInputFailed(result.Remaining[0])
+	}
+
+	c, err := classify(defaultSettings(), string(text), o.Strictness)
+	if err != nil {
+		return quiet, newErrReadFailed()
+	}
+
+	if c == nil || c.Confidence < o.MinConfidence {
+		return quiet, newErrNoMatchingLicense()
+	}
+
+	if want, exists := result.Values["check"]; exists && !c.is(want) {
+		return quiet, newErrCheckFailed(want, c.Key)
+	}
+
+	if quiet {
+		return quiet, nil
+	}
+
+	if _, exists := result.Values["json"]; exists {
+		b, err := json.MarshalIndent(c, "", indent)
+		if err != nil {
+			return quiet, newErrSerializeFailed(c)
+		}
+		fmt.Println(string(b))
+		return quiet, nil
+	}
+
+	fmt.Println(c)
+	return quiet, nil
+}
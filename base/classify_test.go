@@ -0,0 +1,38 @@
+package base
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSimilarity checks the Dice scores of texts
+// at each strictness.
+func TestSimilarity(t *testing.T) {
+	testcases := []struct {
+		name       string
+		a, b       string
+		strictness string
+		want       float64
+	}{
+		{"identical", "Permission is hereby granted", "Permission is hereby granted", strictnessLoose, 1},
+		{"whitespace", "Permission is\n  hereby granted", "Permission is hereby\tgranted", strictnessStrict, 1},
+		{"copyright lines", "Copyright (c) 2016 Jane\nPermission is hereby granted", "Copyright 2020 John Doe\nPermission is hereby granted", strictnessStrict, 1},
+		{"loose ignores case and punctuation", "Permission is hereby granted.", "PERMISSION IS HEREBY GRANTED", strictnessLoose, 1},
+		{"strict compares case", "Permission is hereby granted", "PERMISSION is hereby granted", strictnessStrict, 2.0 / 3},
+		{"one of two bigrams differs", "a b c", "a b d", strictnessLoose, 0.5},
+		{"repeated bigrams match once", "a b a b", "a b", strictnessLoose, 0.5},
+		{"nothing in common", "a b c", "d e f", strictnessLoose, 0},
+		{"empty", "", "", strictnessLoose, 0},
+	}
+
+	for _, tc := range testcases {
+		a := bigrams(classifyTokens(tc.a, tc.strictness))
+		b := bigrams(classifyTokens(tc.b, tc.strictness))
+		if got := similarity(a, b); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		if got := similarity(b, a); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: not symmetric: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
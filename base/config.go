@@ -5,6 +5,7 @@ import (
 	"gopkg.in/nishanths/go-hgconfig.v1"
 	"os"
 	"os/user"
+	"strings"
 )
 
 const (
@@ -12,6 +13,47 @@ const (
 	// of determining the author's name to use on the license.
 	NameEnvVariable = "LICENSE_FULL_NAME"
 	defaultName     = ""
+
+	// SuffixEnvVariable is the environment variable to lookup for an extra
+	// line to append to the copyright notice, such as "All rights reserved.".
+	SuffixEnvVariable = "LICENSE_COPYRIGHT_SUFFIX"
+
+	// MinConfidenceEnvVariable is the environment variable to lookup for
+	// the lowest confidence, between 0 and 1, at which classify accepts a match.
+	MinConfidenceEnvVariable = "LICENSE_MIN_CONFIDENCE"
+
+	// StrictnessEnvVariable is the environment variable to lookup for
+	// how strictly classify compares texts: "loose" or "strict".
+	StrictnessEnvVariable = "LICENSE_CLASSIFY_STRICTNESS"
+
+	// MirrorsEnvVariable is the environment variable to lookup for a
+	// comma-separated list of base URLs to fetch licenses from, in order,
+	// when the GitHub API cannot be reached.
+	MirrorsEnvVariable = "LICENSE_MIRRORS"
+
+	// AuditLogEnvVariable is the environment variable to lookup for the
+	// path of a file to append a JSON line to for each file that license
+	// creates, modifies, or deletes in a project.
+	AuditLogEnvVariable = "LICENSE_AUDIT_LOG"
+
+	// LicenseHomeEnvVariable is the environment variable to lookup for
+	// the directory to keep licenses, settings, and history in, instead
+	// of ~/.license.
+	LicenseHomeEnvVariable = "LICENSE_HOME"
+
+	// CopyrightFormatEnvVariable is the environment variable to lookup for
+	// the template of the copyright notice line, such as
+	// "Copyright (c) {{.Year}}, {{.Org}}".
+	CopyrightFormatEnvVariable = "LICENSE_COPYRIGHT_FORMAT"
+
+	// YearPolicyEnvVariable is the environment variable to lookup for
+	// the year to put on licenses when none is given: "current", "first",
+	// "range", or "none".
+	YearPolicyEnvVariable = "LICENSE_YEAR_POLICY"
+
+	// licenseSettingSection is the git config section for settings
+	// that apply to a single license, such as "license.mit.name".
+	licenseSettingSection = "license"
 )
 
 // getName attempts to implicitly guess the name to use
@@ -39,3 +81,63 @@ func getName() string {
 		return defaultName
 	}
 }
+
+// getSuffix returns the extra copyright notice line configured
+// in the environment, or an empty string if there is none.
+func getSuffix() string {
+	return os.Getenv(SuffixEnvVariable)
+}
+
+// getMirrors returns the fallback base URLs configured in the
+// environment, without trailing slashes.
+func getMirrors() []string {
+	var mirrors []string
+	for _, m := range strings.Split(os.Getenv(MirrorsEnvVariable), ",") {
+		if m = strings.TrimRight(strings.TrimSpace(m), "/"); m != "" {
+			mirrors = append(mirrors, m)
+		}
+	}
+	return mirrors
+}
+
+// getAuditLog returns the path of the audit log configured
+// in the environment, or an empty string if there is none.
+func getAuditLog() string {
+	return os.Getenv(AuditLogEnvVariable)
+}
+
+// getLicenseHome returns the license directory configured
+// in the environment, or an empty string if there is none.
+func getLicenseHome() string {
+	return os.Getenv(LicenseHomeEnvVariable)
+}
+
+// getCopyrightFormat returns the copyright format configured
+// in the environment, or an empty string if there is none.
+func getCopyrightFormat() string {
+	return os.Getenv(CopyrightFormatEnvVariable)
+}
+
+// getYearPolicy returns the year policy configured in
+// the environment, or an empty string if there is none.
+func getYearPolicy() string {
+	return os.Getenv(YearPolicyEnvVariable)
+}
+
+// getLicenseSetting looks up a setting that applies only to the license
+// with the given key, such as the name to use on the Apache License:
+//
+//	git config --global license.apache-2.0.name "Acme Corp"
+//
+// Repository git config takes precedence over global git config.
+// An empty string is returned if the setting does not exist.
+func getLicenseSetting(key, setting string) string {
+	configKey := licenseSettingSection + "." + key + "." + setting
+
+	if v, err := gitconfig.Local(configKey); err == nil {
+		return v
+	} else if v, err := gitconfig.Global(configKey); err == nil {
+		return v
+	}
+	return ""
+}
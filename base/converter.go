@@ -1,8 +1,11 @@
 package base
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -13,6 +16,33 @@ var placeholders = map[string]string{ // depends on api.github.com format
 
 var placeholdersRx *regexp.Regexp
 
+// headerPlaceholders are the placeholders used in the standard
+// headers of licenses, such as the Apache License and the GPL.
+var headerPlaceholders = []struct {
+	Rx     *regexp.Regexp
+	Action string
+}{
+	{regexp.MustCompile(`\[yyyy\]|<year>|\[year\]`), "{{.Year}}"},
+	{regexp.MustCompile(`\[name of copyright owner\]|<name of author>|\[fullname\]`), "{{.Name}}"},
+}
+
+// synthesizedHeader is the header template for licenses
+// whose upstream information has no standard header.
+const synthesizedHeader = `Copyright (c) {{.Year}} {{.Name}}
+{{if .SPDXID}}SPDX-License-Identifier: {{.SPDXID}}
+{{end}}
+This file is licensed under the {{.LicenseName}}.
+See the LICENSE file in the project root for the full license text.
+`
+
+// allRightsReserved is the conventional line appended
+// to the copyright notice with the --rights-reserved flag.
+const allRightsReserved = "All rights reserved."
+
+// suffixAction renders each of the renderOption's Suffix lines
+// on its own line.
+const suffixAction = "{{range .Suffix}}\n{{.}}{{end}}"
+
 func init() {
 	var keys []string
 	for key := range placeholders {
@@ -34,6 +64,44 @@ func textTemplateString(l *License) string {
 	})
 }
 
+// headerTemplateString returns the header template for the license:
+// its standard header, if the upstream information has one, with the
+// placeholders replaced by template actions, otherwise a short
+// synthesized header.
+func headerTemplateString(l *License) string {
+	if strings.TrimSpace(l.Header) == "" {
+		return synthesizedHeader
+	}
+
+	header := escapeTemplate(l.Header)
+	for _, p := range headerPlaceholders {
+		header = p.Rx.ReplaceAllLiteralString(header, p.Action)
+	}
+	return header
+}
+
+// copyrightTemplateName is the name of the template that
+// renders the copyright notice of a license template.
+const copyrightTemplateName = "copyright"
+
+// withCopyrightNotice moves the copyright notice, which is the first line
+// that has a year or name placeholder, into a template of its own, so that
+// a copyright format can replace it when rendering, and inserts an action
+// to render suffix lines right after it. The notice keeps its indentation.
+// Templates without such a line are returned unchanged.
+func withCopyrightNotice(tmpl string) string {
+	lines := strings.Split(tmpl, "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "{{.Year}}") || strings.Contains(line, "{{.Name}}") {
+			notice := strings.TrimLeft(line, " \t")
+			indentation := line[:len(line)-len(notice)]
+			lines[i] = indentation + `{{template "` + copyrightTemplateName + `" .}}` + suffixAction
+			return strings.Join(lines, "\n") + `{{define "` + copyrightTemplateName + `"}}` + notice + "{{end}}"
+		}
+	}
+	return tmpl
+}
+
 func jsonToList(content []byte) ([]License, error) {
 	var licenses []License
 	if err := json.Unmarshal(content, &licenses); err != nil {
@@ -42,6 +110,27 @@ func jsonToList(content []byte) ([]License, error) {
 	return licenses, nil
 }
 
+// licenseKeyRx matches the license keys that are safe to use in file
+// names. Keys come from the index of whichever source served it.
+var licenseKeyRx = regexp.MustCompile(`^[a-z0-9][a-z0-9.+-]*$`)
+
+// checkIndex returns an error if any license in the index has a key
+// that is not safe to use in file names, so that a bad source cannot
+// write files outside the data directory. Nothing of such an index
+// should be written.
+func checkIndex(content []byte) error {
+	licenses, err := jsonToList(content)
+	if err != nil {
+		return newErrDeserializeFailed(content)
+	}
+	for _, l := range licenses {
+		if !licenseKeyRx.MatchString(l.Key) {
+			return newErrInvalidLicenseKey(l.Key)
+		}
+	}
+	return nil
+}
+
 func jsonToLicense(content []byte) (License, error) {
 	var full License
 	if err := json.Unmarshal(content, &full); err != nil {
@@ -49,3 +138,41 @@ func jsonToLicense(content []byte) (License, error) {
 	}
 	return full, nil
 }
+
+// canonicalJSON re-encodes JSON content with object keys in sorted order
+// and fixed indentation, so that equal data always produces equal bytes.
+func canonicalJSON(content []byte) ([]byte, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return encodeCanonicalJSON(v)
+}
+
+// canonicalIndexJSON is like canonicalJSON, and also
+// sorts the entries in the index by license key.
+func canonicalIndexJSON(content []byte) ([]byte, error) {
+	var entries []map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	if err := d.Decode(&entries); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return fmt.Sprint(entries[i]["key"]) < fmt.Sprint(entries[j]["key"])
+	})
+	return encodeCanonicalJSON(entries)
+}
+
+func encodeCanonicalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", indent)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,46 @@
+package base
+
+import (
+	"github.com/tcnksm/go-gitconfig"
+	"strings"
+	"text/template"
+)
+
+// copyrightFormat returns the copyright format for the license with
+// the key: format, if not empty, then the license's copyright setting,
+// then the environment. An empty format keeps the template's own
+// notice. source names where format came from, for errors.
+//
+// A format is a template for the copyright notice line, such as
+//
+//	Copyright © {{.Year}} {{.Name}} <{{.Email}}>
+//
+// and can use the same variables as license templates.
+func copyrightFormat(key, format, source string) (string, error) {
+	if format == "" && key != "" {
+		format, source = getLicenseSetting(key, "copyright"), licenseSettingSection+"."+key+".copyright"
+	}
+	if format == "" {
+		format, source = getCopyrightFormat(), CopyrightFormatEnvVariable
+	}
+
+	if format == "" {
+		return "", nil
+	}
+	if strings.Contains(format, "\n") {
+		return "", newErrInvalidArgument(source, format)
+	}
+	if _, err := template.New(copyrightTemplateName).Parse(format); err != nil {
+		return "", newErrInvalidArgument(source, format)
+	}
+	return format, nil
+}
+
+// getEmail returns the email address to use in a copyright notice,
+// from git config, or an empty string if there is none.
+func getEmail() string {
+	if email, err := gitconfig.Email(); err == nil {
+		return email
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package base
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Custom licenses are kept in the custom directory, which has the same
+// layout as the data directory. Unlike the data directory, it is never
+// replaced by an update.
+
+// customPath returns the path to the custom licenses directory.
+func (s *settings) customPath() (string, error) {
+	root, err := s.storePath()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(root, CustomDirectory), nil
+}
+
+// readCustom returns the contents of a filename or path relative to the custom directory.
+func readCustom(s *settings, f string) ([]byte, error) {
+	p, err := s.customPath()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(filepath.Join(p, f))
+}
+
+// getCustomList returns the custom licenses,
+// or an empty list if there are none.
+func getCustomList(s *settings) ([]License, error) {
+	content, err := readCustom(s, IndexFile)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonToList(content)
+}
+
+// escapeTemplate returns text as a template that renders text verbatim.
+func escapeTemplate(text string) string {
+	return strings.Replace(text, "{{", "{{\"{{\"}}", -1)
+}
+
+// registerCustomLicense adds a license with the given text to the custom
+// licenses. The text is used verbatim as the license's template.
+func registerCustomLicense(s *settings, l *License) error {
+	p, err := s.customPath()
+	if err != nil {
+		return newErrCannotLocateHomeDir()
+	}
+
+	rawPath := filepath.Join(p, RawDirectory)
+	templatesPath := filepath.Join(p, TemplatesDirectory)
+	indexFilePath := filepath.Join(p, IndexFile)
+
+	for _, d := range []string{rawPath, templatesPath} {
+		if err := os.MkdirAll(d, perm); err != nil {
+			return newErrCreateDirFailed(d)
+		}
+	}
+
+	content, err := json.Marshal(l)
+	if err != nil {
+		return newErrSerializeFailed(l)
+	}
+
+	canonical, err := canonicalJSON(content)
+	if err != nil {
+		return newErrSerializeFailed(l)
+	}
+
+	rawFilePath := filepath.Join(rawPath, l.Key+".json")
+	if err := ioutil.WriteFile(rawFilePath, canonical, perm); err != nil {
+		return newErrWriteFileFailed(rawFilePath)
+	}
+
+	templateFilePath := filepath.Join(templatesPath, l.Key+".tmpl")
+	if err := ioutil.WriteFile(templateFilePath, []byte(escapeTemplate(l.Body)), perm); err != nil {
+		return newErrWriteFileFailed(templateFilePath)
+	}
+
+	if err := writeHeaderTemplate(l, templatesPath); err != nil {
+		return err
+	}
+
+	// add the license to the index, without its body
+	licenses, err := getCustomList(s)
+	if err != nil {
+		return newErrReadFailed()
+	}
+
+	entry := *l
+	entry.Body = ""
+	index, err := json.Marshal(append(licenses, entry))
+	if err != nil {
+		return newErrSerializeFailed(licenses)
+	}
+
+	canonical, err = canonicalIndexJSON(index)
+	if err != nil {
+		return newErrSerializeFailed(licenses)
+	}
+
+	if err := ioutil.WriteFile(indexFilePath, canonical, perm); err != nil {
+		return newErrWriteFileFailed(indexFilePath)
+	}
+
+	// like the data directory, the custom directory has a manifest,
+	// and does not depend on when its files were written
+	if err := writeManifest(p); err != nil {
+		return err
+	}
+	if err := setStoreModTimes(p); err != nil {
+		return newErrWriteFileFailed(p)
+	}
+
+	return nil
+}
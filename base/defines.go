@@ -1,11 +1,24 @@
 package base
 
+import "time"
+
 const (
 	LicenseDirectory   = ".license"
 	DataDirectory      = "data"
+	CustomDirectory    = "custom"
 	IndexFile          = "licenses.json"
 	RawDirectory       = "raw"
 	TemplatesDirectory = "tmpl"
+	ManifestFile       = "manifest.json"
+	VersionFile        = "version"
+	MigrationLogFile   = "migrations.log"
+	UndoDirectory      = "undo"
+	JournalFile        = "journal.jsonl"
+	HistoryFile        = "history.jsonl"
+	SourcesFile        = "sources.json"
+	PrefetchPidFile    = "prefetch.pid"
+	ObjectsDirectory   = "objects"
+	RefsFile           = "refs.json"
 	tempDirPrefix      = "license"
 
 	applicationVersion  = "0.1.2"
@@ -14,4 +27,11 @@ const (
 
 	indent = "    "
 	perm   = 0700
+
+	exitCodeInterrupted = 130
+	exitCodeTimedOut    = 124
 )
+
+// storeModTime is the fixed modification time given to files
+// in the local license store.
+var storeModTime = time.Unix(0, 0).UTC()
@@ -0,0 +1,108 @@
+package base
+
+import (
+	"strings"
+	"text/template"
+)
+
+// deprecatedSpdxIDs maps deprecated SPDX license identifiers to their
+// replacements. The first replacement has the same meaning as the
+// deprecated identifier.
+var deprecatedSpdxIDs = map[string][]string{
+	"AGPL-1.0":  {"AGPL-1.0-only", "AGPL-1.0-or-later"},
+	"AGPL-3.0":  {"AGPL-3.0-only", "AGPL-3.0-or-later"},
+	"GFDL-1.1":  {"GFDL-1.1-only", "GFDL-1.1-or-later"},
+	"GFDL-1.2":  {"GFDL-1.2-only", "GFDL-1.2-or-later"},
+	"GFDL-1.3":  {"GFDL-1.3-only", "GFDL-1.3-or-later"},
+	"GPL-1.0":   {"GPL-1.0-only", "GPL-1.0-or-later"},
+	"GPL-1.0+":  {"GPL-1.0-or-later"},
+	"GPL-2.0":   {"GPL-2.0-only", "GPL-2.0-or-later"},
+	"GPL-2.0+":  {"GPL-2.0-or-later"},
+	"GPL-3.0":   {"GPL-3.0-only", "GPL-3.0-or-later"},
+	"GPL-3.0+":  {"GPL-3.0-or-later"},
+	"LGPL-2.0":  {"LGPL-2.0-only", "LGPL-2.0-or-later"},
+	"LGPL-2.0+": {"LGPL-2.0-or-later"},
+	"LGPL-2.1":  {"LGPL-2.1-only", "LGPL-2.1-or-later"},
+	"LGPL-2.1+": {"LGPL-2.1-or-later"},
+	"LGPL-3.0":  {"LGPL-3.0-only", "LGPL-3.0-or-later"},
+	"LGPL-3.0+": {"LGPL-3.0-or-later"},
+}
+
+// spdxReplacements returns the SPDX identifiers that replace the
+// identifier, ignoring case, or nil if it is not deprecated.
+func spdxReplacements(id string) []string {
+	for deprecated, r := range deprecatedSpdxIDs {
+		if strings.EqualFold(deprecated, id) {
+			return r
+		}
+	}
+	return nil
+}
+
+// currentSpdxID returns the identifier that replaces a deprecated one
+// with the same meaning, such as GPL-3.0-only for GPL-3.0, or id itself
+// if it is not deprecated.
+func currentSpdxID(id string) string {
+	if r := spdxReplacements(id); r != nil {
+		return r[0]
+	}
+	return id
+}
+
+// sameSpdxID returns true if the SPDX identifiers name the same license,
+// ignoring case. A deprecated identifier is the same as each of its
+// replacements: GitHub and the classifier use "GPL-3.0" for the text
+// of the GPL, which does not say whether "-only" or "-or-later" applies.
+func sameSpdxID(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	for _, r := range spdxReplacements(a) {
+		if strings.EqualFold(r, b) {
+			return true
+		}
+	}
+	for _, r := range spdxReplacements(b) {
+		if strings.EqualFold(r, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// replacements returns the SPDX identifiers that replace the
+// license's deprecated identifier, or nil if it is not deprecated.
+func (l *License) replacements() []string {
+	return deprecatedSpdxIDs[l.SpdxID]
+}
+
+// upgrade returns the license that succeeds a license with a deprecated
+// SPDX identifier. A local license with the successor identifier is
+// preferred, and may need to be fetched; otherwise the successor has
+// the same text and the successor identifier.
+func (l License) upgrade(licenses []License) License {
+	r := l.replacements()
+	if r == nil {
+		return l
+	}
+
+	for _, other := range licenses {
+		if other.SpdxID == r[0] || strings.EqualFold(other.Key, r[0]) {
+			return other
+		}
+	}
+
+	l.SpdxID = r[0]
+	return l
+}
+
+// usesSpdxID returns true if the template, or a template it defines,
+// such as the copyright notice, refers to {{.SPDXID}}.
+func usesSpdxID(tmpl *template.Template) bool {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && strings.Contains(t.Tree.Root.String(), ".SPDXID") {
+			return true
+		}
+	}
+	return false
+}
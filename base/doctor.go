@@ -0,0 +1,214 @@
+package base
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// probeTimeout bounds each probe of a source.
+	probeTimeout = 10 * time.Second
+
+	// certExpiryWarning is how close to expiring a
+	// certificate gets before doctor warns about it.
+	certExpiryWarning = 14 * 24 * time.Hour
+)
+
+// sourceHealth is the result of probing a source.
+type sourceHealth struct {
+	Source    string
+	Latency   time.Duration
+	Status    string
+	RateLimit string
+	TLS       string
+	Problem   string
+}
+
+func (h *sourceHealth) String() string {
+	if h.Problem != "" {
+		return fmt.Sprintf("%s%-32s error  %s", indent, sourceName(h.Source), h.Problem)
+	}
+
+	details := []string{h.Latency.Round(time.Millisecond).String()}
+	if h.RateLimit != "" {
+		details = append(details, "rate limit "+h.RateLimit+" remaining")
+	}
+	if h.TLS != "" {
+		details = append(details, h.TLS)
+	}
+	return fmt.Sprintf("%s%-32s ok     %s", indent, sourceName(h.Source), strings.Join(details, ", "))
+}
+
+// describeTLSError returns a description of a certificate
+// verification error, or an empty string if err is not one.
+func describeTLSError(err error) string {
+	var unknown x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &unknown):
+		return "TLS certificate is signed by an unknown authority"
+	case errors.As(err, &hostname):
+		return "TLS certificate is not valid for " + hostname.Host
+	case errors.As(err, &invalid):
+		return "TLS certificate is invalid: " + invalid.Error()
+	}
+	return ""
+}
+
+// probeSource requests the index from the source, and reports how long
+// it took, whether the credentials in the environment were accepted,
+// how many requests the rate limit has left, and the state of the TLS
+// certificate chain.
+func probeSource(ctx context.Context, s *settings, source string) *sourceHealth {
+	h := &sourceHealth{Source: source}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", source+gitHubAPILicensesPath, nil)
+	if err == nil {
+		err = prepareRequest(req)
+	}
+	if err != nil {
+		h.Problem = "invalid URL: " + err.Error()
+		return h
+	}
+
+	start := time.Now()
+	resp, err := s.httpClient().Do(req.WithContext(ctx))
+	h.Latency = time.Since(start)
+
+	if err != nil {
+		if d := describeTLSError(err); d != "" {
+			h.Problem = d
+		} else if ctx.Err() != nil {
+			h.Problem = fmt.Sprintf("no response within %v", probeTimeout)
+		} else if uerr, ok := err.(*url.Error); ok {
+			h.Problem = uerr.Err.Error()
+		} else {
+			h.Problem = err.Error()
+		}
+		return h
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	h.Status = resp.Status
+	h.RateLimit = resp.Header.Get("X-RateLimit-Remaining")
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		h.Problem = fmt.Sprintf("credentials in %s and %s were rejected (%s)", gitHubClientIDEnvVariable, gitHubClientSecretEnvVariable, resp.Status)
+		return h
+	case h.RateLimit == "0":
+		h.Problem = "rate limit exhausted (" + resp.Status + ")"
+		return h
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		h.Problem = "unexpected response " + resp.Status
+		return h
+	}
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		leaf := resp.TLS.PeerCertificates[0]
+		left := time.Until(leaf.NotAfter)
+		h.TLS = fmt.Sprintf("TLS certificate valid until %s", leaf.NotAfter.Format("2006-01-02"))
+		if left < certExpiryWarning {
+			h.Problem = fmt.Sprintf("TLS certificate expires in %d days", int(left.Hours()/24))
+		}
+	}
+
+	return h
+}
+
+// checkLocal reports problems with the local licenses.
+func checkLocal(s *settings) []string {
+	var problems []string
+
+	content, err := readIndex(s)
+	if err != nil {
+		return []string{"local licenses are missing; run \"license update\""}
+	}
+
+	licenses, err := jsonToList(content)
+	if err != nil {
+		return []string{"local index is corrupted; run \"license update --repair\""}
+	}
+
+	var broken []string
+	unfetched := 0
+	for i := range licenses {
+		switch {
+		case !licenses[i].isFetched(s):
+			unfetched++
+		case !licenses[i].isHealthy(s):
+			broken = append(broken, licenses[i].Key)
+		}
+	}
+	if unfetched > 0 {
+		// not a problem: they are fetched when first used
+		fmt.Printf("%s%d license(s) are not fetched yet; run \"license update --repair\" to fetch them all\n", indent, unfetched)
+	}
+	if len(broken) > 0 {
+		problems = append(problems, fmt.Sprintf("%d local license(s) are broken: %s; run \"license update --repair\"", len(broken), strings.Join(broken, ", ")))
+	}
+
+	return problems
+}
+
+// Doctor checks the local licenses, then probes each source that
+// licenses are fetched from, the GitHub API and any mirrors, and
+// reports the state of each, so that a misconfigured source stands out.
+func Doctor(args []string) error {
+	if len(args) > 0 {
+		return newErrUnknownArgument(args...)
+	}
+
+	s := defaultSettings()
+	problems := 0
+
+	fmt.Println("Local licenses:")
+	local := checkLocal(s)
+	for _, p := range local {
+		fmt.Println(indent + p)
+	}
+	if len(local) == 0 {
+		fmt.Println(indent + "ok")
+	}
+	problems += len(local)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	fmt.Println()
+	fmt.Println("Sources:")
+	for _, source := range sources(s) {
+		h := probeSource(ctx, s, source)
+		if ctx.Err() != nil {
+			return contextError(ctx)
+		}
+		fmt.Println(h)
+		if h.Problem != "" {
+			problems++
+		}
+	}
+
+	if len(sources(s)) < 2 {
+		fmt.Println(indent + "(add mirrors with \"license sources add <name> <url>\")")
+	}
+
+	if problems > 0 {
+		return newErrDoctorFoundProblems(problems)
+	}
+
+	return nil
+}
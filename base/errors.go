@@ -3,6 +3,7 @@ package base
 import (
 	"fmt"
 	"text/template"
+	"time"
 )
 
 // generalized error types
@@ -72,6 +73,15 @@ type errParsingArguments errBasicError
 type errCannotLocateHomeDir errBasicError
 type errExpectedLicenseName errBasicError
 type errCannotFindLicense errBasicError
+type errInterrupted errBasicError
+type errExpectedFilename errBasicError
+type errNoMatchingLicense errBasicError
+type errExpectedModule errBasicError
+type errLockWithoutOutput errBasicError
+type errExpectedDirectory errBasicError
+type errNothingToUndo errBasicError
+type errNoCatalogHistory errBasicError
+type errNoSources errBasicError
 
 func (err *errReadFailed) Error() string {
 	return basicErrorString(err.Description, err.Suggestion)
@@ -91,6 +101,33 @@ func (err *errExpectedLicenseName) Error() string {
 func (err *errCannotFindLicense) Error() string {
 	return basicErrorString(err.Description, err.Suggestion)
 }
+func (err *errInterrupted) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
+func (err *errExpectedFilename) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
+func (err *errNoMatchingLicense) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
+func (err *errExpectedModule) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
+func (err *errLockWithoutOutput) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
+func (err *errExpectedDirectory) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
+func (err *errNothingToUndo) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
+func (err *errNoCatalogHistory) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
+func (err *errNoSources) Error() string {
+	return basicErrorString(err.Description, err.Suggestion)
+}
 
 // data errors
 
@@ -98,6 +135,30 @@ type errSerializeFailed errDataError
 type errDeserializeFailed errDataError
 type errLoadingTemplate errDataError
 type errExecutingTemplate errDataError
+type errMissingSpdxID errDataError
+type errLicenseFileNotFound errDataError
+type errDownloadModuleFailed errDataError
+type errNoLicenseFiles errDataError
+type errImportFailed errDataError
+type errTimedOut errDataError
+type errProjectLicenseNotFound errDataError
+type errAnnotateFailed errDataError
+type errStoreTooNew errDataError
+type errDoctorFoundProblems errDataError
+type errInvalidSource errDataError
+type errSourceExists errDataError
+type errUnknownSource errDataError
+type errRemoveBuiltinSource errDataError
+type errLicenseConflicts errDataError
+type errNoSchema errDataError
+type errInvalidTargets errDataError
+type errCorruptedObject errDataError
+type errClauseNotFound errDataError
+type errGoldenMismatch errDataError
+type errInvalidTemplate errDataError
+type errInvalidVars errDataError
+type errInvalidLicenseKey errDataError
+type errSameLicense errDataError
 
 func (err *errSerializeFailed) Error() string {
 	return dataErrorString(err.Description, err.Suggestion, err.Data)
@@ -111,11 +172,87 @@ func (err *errLoadingTemplate) Error() string {
 func (err *errExecutingTemplate) Error() string {
 	return dataErrorString(err.Description, err.Suggestion, err.Data)
 }
+func (err *errMissingSpdxID) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errLicenseFileNotFound) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errDownloadModuleFailed) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errNoLicenseFiles) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errImportFailed) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errTimedOut) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errProjectLicenseNotFound) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errAnnotateFailed) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errStoreTooNew) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errDoctorFoundProblems) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errInvalidSource) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errLicenseConflicts) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errNoSchema) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errInvalidTargets) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errCorruptedObject) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errClauseNotFound) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errGoldenMismatch) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errInvalidTemplate) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errInvalidVars) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errInvalidLicenseKey) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errSameLicense) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errSourceExists) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errUnknownSource) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
+func (err *errRemoveBuiltinSource) Error() string {
+	return dataErrorString(err.Description, err.Suggestion, err.Data)
+}
 
 // argument errors
 
 type errUnknownArgument errArgumentError
 type errBadArgumentSyntax errArgumentError
+type errInvalidArgument errArgumentError
+type errExpectedSourceArgs errArgumentError
+type errIncompatibleFlags errArgumentError
+type errExpectedExplainArgs errArgumentError
 
 func (err *errUnknownArgument) Error() string {
 	return argumentErrorString(err.Description, err.Suggestion, err.Args)
@@ -123,6 +260,18 @@ func (err *errUnknownArgument) Error() string {
 func (err *errBadArgumentSyntax) Error() string {
 	return argumentErrorString(err.Description, err.Suggestion, err.Args)
 }
+func (err *errInvalidArgument) Error() string {
+	return argumentErrorString(err.Description, err.Suggestion, err.Args)
+}
+func (err *errExpectedSourceArgs) Error() string {
+	return argumentErrorString(err.Description, err.Suggestion, err.Args)
+}
+func (err *errExpectedExplainArgs) Error() string {
+	return argumentErrorString(err.Description, err.Suggestion, err.Args)
+}
+func (err *errIncompatibleFlags) Error() string {
+	return argumentErrorString(err.Description, err.Suggestion, err.Args)
+}
 
 // path errors
 
@@ -130,6 +279,10 @@ type errCreateTempDirFailed errPathError
 type errWriteFileFailed errPathError
 type errCreateDirFailed errPathError
 type errRemovePathFailed errPathError
+type errReadInputFailed errPathError
+type errUpdateManifestFailed errPathError
+type errChangedSinceCommand errPathError
+type errFileExists errPathError
 
 func (err *errCreateTempDirFailed) Error() string {
 	return pathErrorString(err.Description, err.Suggestion, err.Paths)
@@ -143,6 +296,40 @@ func (err *errCreateDirFailed) Error() string {
 func (err *errRemovePathFailed) Error() string {
 	return pathErrorString(err.Description, err.Suggestion, err.Paths)
 }
+func (err *errReadInputFailed) Error() string {
+	return pathErrorString(err.Description, err.Suggestion, err.Paths)
+}
+func (err *errUpdateManifestFailed) Error() string {
+	return pathErrorString(err.Description, err.Suggestion, err.Paths)
+}
+func (err *errChangedSinceCommand) Error() string {
+	return pathErrorString(err.Description, err.Suggestion, err.Paths)
+}
+func (err *errFileExists) Error() string {
+	return pathErrorString(err.Description, err.Suggestion, err.Paths)
+}
+
+// check failed error
+
+type errCheckFailed struct {
+	Want, Got string
+}
+
+func (err *errCheckFailed) Error() string {
+	return fmt.Sprintf("license: check failed: expected %s, found %s", err.Want, err.Got)
+}
+
+// silent error
+
+// errSilent wraps an error that should affect the exit code
+// without being printed.
+type errSilent struct {
+	Err error
+}
+
+func (err *errSilent) Error() string {
+	return err.Err.Error()
+}
 
 // copy tree error
 
@@ -161,7 +348,7 @@ func (err *errCopyTreeFailed) Error() string {
 func newErrReadFailed() error {
 	return &errReadFailed{
 		"failed to read license(s)",
-		"try again after running \"license update -v\"",
+		"try again after running \"license update --repair\"",
 	}
 }
 
@@ -198,6 +385,69 @@ func newErrCannotFindLicense() error {
 	}
 }
 
+func newErrInterrupted() error {
+	return &errInterrupted{
+		"interrupted",
+		"local licenses were left unchanged",
+	}
+}
+
+func newErrExpectedFilename() error {
+	return &errExpectedFilename{
+		"expected a filename, or \"-\" to read from standard input",
+		"see \"license help\" for more details",
+	}
+}
+
+func newErrNoMatchingLicense() error {
+	return &errNoMatchingLicense{
+		"no matching license found",
+		"run \"license ls\" for a list of known licenses",
+	}
+}
+
+func newErrExpectedModule() error {
+	return &errExpectedModule{
+		"expected a module path, such as github.com/foo/bar@v1.2.3",
+		"see \"license help\" for more details",
+	}
+}
+
+func newErrLockWithoutOutput() error {
+	return &errLockWithoutOutput{
+		"--lock needs a license file to record",
+		"use \"-o\" or \"-p\" to save the license to a file",
+	}
+}
+
+func newErrExpectedDirectory() error {
+	return &errExpectedDirectory{
+		"expected a directory",
+		"see \"license help\" for more details",
+	}
+}
+
+func newErrNothingToUndo() error {
+	return &errNothingToUndo{
+		"nothing to undo",
+		"only the file changes of the last command that changed files can be undone",
+	}
+}
+
+func newErrNoCatalogHistory() error {
+	return &errNoCatalogHistory{
+		"no earlier record of the upstream licenses to compare with",
+		"the current licenses were recorded; run \"license changes\" again after they change",
+	}
+}
+
+func newErrNoSources() error {
+	return &errNoSources{
+		"no sources are enabled",
+		"enable one with \"license sources enable github\"",
+	}
+}
+
 // data errors
 
 func newErrSerializeFailed(l interface{}) error {
@@ -219,7 +469,7 @@ func newErrDeserializeFailed(data []byte) error {
 func newErrLoadingTemplate(name string) error {
 	return &errLoadingTemplate{
 		"failed to load template",
-		fmt.Sprintf("please create an issue at %s", repositoryIssuesURL),
+		"try again after running \"license update --repair\"",
 		name,
 	}
 }
@@ -232,6 +482,198 @@ func newErrExecutingTemplate(t *template.Template) error {
 	}
 }
 
+func newErrMissingSpdxID(key string) error {
+	return &errMissingSpdxID{
+		"no SPDX identifier for license",
+		"try again after running \"license update\"",
+		key,
+	}
+}
+
+func newErrLicenseFileNotFound(key string) error {
+	return &errLicenseFileNotFound{
+		"unable to find the project's license file for",
+		"run \"license classify <file>\" to check which license a file has",
+		key,
+	}
+}
+
+func newErrDownloadModuleFailed(module string, reason interface{}) error {
+	return &errDownloadModuleFailed{
+		"failed to download module " + module + ":",
+		"check the module path and version, and that the go command is installed",
+		reason,
+	}
+}
+
+func newErrNoLicenseFiles(module string) error {
+	return &errNoLicenseFiles{
+		"no license files found in module",
+		"",
+		module,
+	}
+}
+
+func newErrImportFailed(n int) error {
+	return &errImportFailed{
+		"failed to import license files:",
+		"",
+		n,
+	}
+}
+
+func newErrProjectLicenseNotFound(dir string) error {
+	return &errProjectLicenseNotFound{
+		"unable to identify the license of the project in",
+		"add a license file with \"license -p <license-name>\" and try again",
+		dir,
+	}
+}
+
+func newErrAnnotateFailed(n int) error {
+	return &errAnnotateFailed{
+		"failed to annotate files:",
+		"",
+		n,
+	}
+}
+
+func newErrStoreTooNew(v int) error {
+	return &errStoreTooNew{
+		"local licenses were written by a newer version of license, layout version",
+		"upgrade license, or run \"license update\" to recreate them",
+		v,
+	}
+}
+
+func newErrDoctorFoundProblems(n int) error {
+	return &errDoctorFoundProblems{
+		"problems found:",
+		"",
+		n,
+	}
+}
+
+func newErrLicenseConflicts(n int) error {
+	return &errLicenseConflicts{
+		"conflicting license statements:",
+		"make the manifest, source files, and README agree with the license files",
+		n,
+	}
+}
+
+func newErrNoSchema(command string) error {
+	return &errNoSchema{
+		"no JSON output schema for command",
+		"run \"license schema\" for the commands with JSON output",
+		command,
+	}
+}
+
+func newErrInvalidTargets(p, reason string) error {
+	return &errInvalidTargets{
+		"invalid targets in",
+		"",
+		p + ": " + reason,
+	}
+}
+
+func newErrCorruptedObject(sum string) error {
+	return &errCorruptedObject{
+		"local license data does not match its checksum",
+		"run \"license update --repair\" to fetch it again",
+		sum,
+	}
+}
+
+func newErrClauseNotFound(key, keyword string) error {
+	return &errClauseNotFound{
+		"no section of",
+		"try another keyword, such as \"patent\" or \"warranty\"",
+		fmt.Sprintf("%s mentions %q", key, keyword),
+	}
+}
+
+func newErrGoldenMismatch(golden string, lines int) error {
+	return &errGoldenMismatch{
+		"rendered template does not match",
+		"run again with \"--update\" if the change is intended",
+		fmt.Sprintf("%s (%d line(s) differ)", golden, lines),
+	}
+}
+
+func newErrInvalidTemplate(p, reason string) error {
+	return &errInvalidTemplate{
+		"invalid template",
+		"",
+		p + ": " + reason,
+	}
+}
+
+func newErrInvalidVars(p, reason string) error {
+	return &errInvalidVars{
+		"invalid vars in",
+		"",
+		p + ": " + reason,
+	}
+}
+
+func newErrInvalidLicenseKey(key string) error {
+	return &errInvalidLicenseKey{
+		"the source serves a license with an invalid key:",
+		"check the sources with \"license sources\"",
+		fmt.Sprintf("%q", key),
+	}
+}
+
+func newErrSameLicense(key string) error {
+	return &errSameLicense{
+		"the project would be relicensed to the license it has,",
+		"name a different license to relicense to",
+		key,
+	}
+}
+
+func newErrInvalidSource(rawURL, reason string) error {
+	return &errInvalidSource{
+		"not adding source, it failed validation:",
+		"",
+		rawURL + ": " + reason,
+	}
+}
+
+func newErrSourceExists(name string) error {
+	return &errSourceExists{
+		"there is already a source named",
+		"choose another name, or remove the existing source first",
+		name,
+	}
+}
+
+func newErrUnknownSource(name string) error {
+	return &errUnknownSource{
+		"there is no source named",
+		"run \"license sources\" to list the sources",
+		name,
+	}
+}
+
+func newErrRemoveBuiltinSource(name string) error {
+	return &errRemoveBuiltinSource{
+		"cannot remove the built-in source",
+		"use \"license sources disable " + name + "\" instead",
+		name,
+	}
+}
+
+func newErrTimedOut(d time.Duration) error {
+	return &errTimedOut{
+		"timed out after",
+		"check your internet connection, or try again with a longer \"--timeout\"",
+		d,
+	}
+}
+
 // path errors
 
 func newErrCreateTempDirFailed(p ...string) error {
@@ -252,6 +694,36 @@ func newErrRemovePathFailed(p ...string) error {
 	}
 }
 
+func newErrReadInputFailed(p ...string) error {
+	return &errReadInputFailed{
+		"failed to read input", "", p,
+	}
+}
+
+func newErrUpdateManifestFailed(p ...string) error {
+	return &errUpdateManifestFailed{
+		"failed to update license in manifest",
+		"update the license field by hand, or use \"-o\" to skip the manifest",
+		p,
+	}
+}
+
+func newErrChangedSinceCommand(p ...string) error {
+	return &errChangedSinceCommand{
+		"files changed since the last command, not undoing:",
+		"use \"--force\" to undo anyway and lose those changes",
+		p,
+	}
+}
+
+func newErrFileExists(p ...string) error {
+	return &errFileExists{
+		"not overwriting existing file",
+		"use \"--force\" to overwrite it",
+		p,
+	}
+}
+
 func newErrWriteFileFailed(p ...string) error {
 	return &errWriteFileFailed{
 		"failed to write file", "", p,
@@ -268,6 +740,30 @@ func newErrUnknownArgument(args ...string) error {
 	}
 }
 
+func newErrIncompatibleFlags(flags ...string) error {
+	return &errIncompatibleFlags{
+		"flags cannot be used together:",
+		"see \"license help\" for more details",
+		flags,
+	}
+}
+
+func newErrExpectedSourceArgs(command, usage string) error {
+	return &errExpectedSourceArgs{
+		"expected arguments:",
+		"see \"license help\" for more details",
+		[]string{"license sources " + command + " " + usage},
+	}
+}
+
+func newErrExpectedExplainArgs(keywords []string) error {
+	return &errExpectedExplainArgs{
+		"expected a license and a clause keyword, such as:",
+		"see \"license help\" for more details",
+		keywords,
+	}
+}
+
 func newErrBadFlagSyntax(args ...string) error {
 	return &errBadArgumentSyntax{
 		"bad flag",
@@ -276,8 +772,76 @@ func newErrBadFlagSyntax(args ...string) error {
 	}
 }
 
+func newErrInvalidArgument(args ...string) error {
+	return &errInvalidArgument{
+		"invalid argument value",
+		"see \"license help\" for more details",
+		args,
+	}
+}
+
+// check failed error
+
+func newErrCheckFailed(want, got string) error {
+	return &errCheckFailed{Want: want, Got: got}
+}
+
+// silent error
+
+// silence returns err wrapped so that it is not printed,
+// or nil if err is nil.
+func silence(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &errSilent{err}
+}
+
 // copy tree error
 
 func newErrCopyTreeFailed(from, to string) error {
 	return &errCopyTreeFailed{From: from, To: to}
 }
+
+// IsInterrupted returns true if the error was caused
+// by the process receiving an interrupt signal.
+func IsInterrupted(err error) bool {
+	if s, ok := err.(*errSilent); ok {
+		err = s.Err
+	}
+	_, ok := err.(*errInterrupted)
+	return ok
+}
+
+// IsTimedOut returns true if the error was caused
+// by a command running past its timeout.
+func IsTimedOut(err error) bool {
+	if s, ok := err.(*errSilent); ok {
+		err = s.Err
+	}
+	_, ok := err.(*errTimedOut)
+	return ok
+}
+
+// IsSilent returns true if the error should not be printed,
+// because the command was asked to convey its result only
+// through the exit code.
+func IsSilent(err error) bool {
+	_, ok := err.(*errSilent)
+	return ok
+}
+
+// ExitCode returns the exit code the program should use
+// for the given error.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case IsInterrupted(err):
+		return exitCodeInterrupted
+	case IsTimedOut(err):
+		return exitCodeTimedOut
+	default:
+		return 1
+	}
+}
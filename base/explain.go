@@ -0,0 +1,254 @@
+package base
+
+import (
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultExplainContext is the number of paragraphs printed
+// on each side of a paragraph that mentions the clause.
+const defaultExplainContext = 1
+
+// clause is a topic that license texts cover, the words that
+// license texts use for it, and its rule, if it has one.
+type clause struct {
+	Terms []string
+	Rule  Rule
+}
+
+var (
+	// clauses are the known clause keywords. Other keywords
+	// are looked up as they are.
+	clauses = map[string]clause{
+		"patent":       {[]string{"patent"}, PatentUse},
+		"attribution":  {[]string{"attribution", "copyright notice", "above copyright", "this permission notice", "notice"}, IncludeCopyright},
+		"notice":       {[]string{"notice"}, IncludeCopyright},
+		"tivoization":  {[]string{"installation information", "user product", "tivo"}, ""},
+		"warranty":     {[]string{"warrant"}, Warranty},
+		"liability":    {[]string{"liab", "damages"}, Liability},
+		"trademark":    {[]string{"trademark", "trade name"}, TrademarkUse},
+		"source":       {[]string{"source code", "corresponding source", "source form"}, DiscloseSource},
+		"network":      {[]string{"network", "remote"}, NetworkUseDisclose},
+		"changes":      {[]string{"modified", "changes", "modification"}, DocumentChanges},
+		"copyleft":     {[]string{"same license", "under this license", "same terms"}, SameLicense},
+		"commercial":   {[]string{"commercial", "sell", "charge"}, CommercialUse},
+		"termination":  {[]string{"terminat"}, ""},
+		"sublicense":   {[]string{"sublicens"}, ""},
+		"distribution": {[]string{"distribut", "convey"}, Distribution},
+	}
+
+	// sectionHeadingRx matches the first line of a section, such as
+	// "3. Grant of Patent License.", "Section 4", or "TERMS AND CONDITIONS".
+	sectionHeadingRx = regexp.MustCompile(`^\s*(?:\d+[.)]\s|\(?[a-z]\)\s|Section \d+|[A-Z][A-Z ,.'-]{3,}$)`)
+
+	sectionNumberRx = regexp.MustCompile(`^(?:\d+[.)]|\(?[a-z]\)|Section \d+\.?)\s*`)
+)
+
+// section is a numbered or titled part of a license text,
+// made of paragraphs.
+type section struct {
+	Title      string
+	Paragraphs []string
+}
+
+// sections splits a license text into paragraphs, and groups them
+// into sections, each starting at a paragraph that looks like a
+// heading. Texts without headings are a single section.
+func sections(body string) []section {
+	var result []section
+	current := section{}
+
+	for _, p := range strings.Split(strings.Replace(body, "\r\n", "\n", -1), "\n\n") {
+		p = strings.Trim(p, "\n")
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+
+		firstLine := strings.SplitN(p, "\n", 2)[0]
+		if sectionHeadingRx.MatchString(firstLine) && len(current.Paragraphs) > 0 {
+			result = append(result, current)
+			current = section{}
+		}
+		if len(current.Paragraphs) == 0 {
+			current.Title = sectionTitle(firstLine)
+		}
+		current.Paragraphs = append(current.Paragraphs, p)
+	}
+
+	if len(current.Paragraphs) > 0 {
+		result = append(result, current)
+	}
+	return result
+}
+
+// sectionTitle returns the title of a section from the first line of its
+// heading, which often runs on into the text, as in "3. Grant of Patent
+// License. Subject to the terms...".
+func sectionTitle(line string) string {
+	line = strings.TrimSpace(line)
+	number := sectionNumberRx.FindString(line)
+	if i := strings.Index(line[len(number):], ". "); i >= 0 {
+		return line[:len(number)+i+1]
+	}
+	return line
+}
+
+// mentions returns true if the paragraph uses any of the terms.
+func mentions(paragraph string, terms []string) bool {
+	p := strings.ToLower(paragraph)
+	for _, t := range terms {
+		if strings.Contains(p, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// excerpt returns the paragraphs of the section that mention the terms,
+// with n paragraphs of context on each side, or nil if none do. Gaps
+// between the paragraphs returned are marked with "...".
+func (s *section) excerpt(terms []string, n int) []string {
+	keep := make([]bool, len(s.Paragraphs))
+	found := false
+	for i, p := range s.Paragraphs {
+		if !mentions(p, terms) {
+			continue
+		}
+		found = true
+		for j := i - n; j <= i+n; j++ {
+			if j >= 0 && j < len(keep) {
+				keep[j] = true
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	var result []string
+	for i, p := range s.Paragraphs {
+		switch {
+		case keep[i]:
+			result = append(result, p)
+		case i > 0 && keep[i-1]:
+			result = append(result, "...")
+		}
+	}
+	if !keep[0] {
+		result = append([]string{"..."}, result...)
+	}
+	return result
+}
+
+// describeRule returns a sentence on whether the license lists the
+// rule as a permission, condition, or limitation.
+func describeRule(l *License, r Rule) string {
+	rules := l.Rules()
+	name := l.SpdxID
+	if name == "" {
+		name = l.Name
+	}
+
+	switch {
+	case rules.Permits(r):
+		return fmt.Sprintf("%s lists %q as a permission.", name, r.Label())
+	case rules.Requires(r):
+		return fmt.Sprintf("%s lists %q as a condition.", name, r.Label())
+	case rules.Limits(r):
+		return fmt.Sprintf("%s lists %q as a limitation.", name, r.Label())
+	}
+	return fmt.Sprintf("%s does not list %q among its rules.", name, r.Label())
+}
+
+// clauseKeywords returns the known clause keywords, sorted.
+func clauseKeywords() []string {
+	var keywords []string
+	for k := range clauses {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+// Explain prints the sections of a license's text that cover a clause,
+// such as what Apache-2.0 says about patents:
+//
+//	license explain apache-2.0 patent
+//
+// Known clause keywords, such as "attribution" or "tivoization", are
+// expanded to the words license texts use for them; other keywords
+// are looked up as they are. The paragraphs that mention the clause
+// are printed with the paragraphs around them, --context of them.
+func Explain(args []string) error {
+	flagSet := simpleflag.NewFlagSet("explain")
+	flagSet.Add("context", []string{"--context", "-context"}, false)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	if len(result.Remaining) != 2 {
+		return newErrExpectedExplainArgs(clauseKeywords())
+	}
+
+	n := defaultExplainContext
+	if v, exists := result.Values["context"]; exists {
+		if n, err = strconv.Atoi(v); err != nil || n < 0 {
+			return newErrInvalidArgument("--context", v)
+		}
+	}
+
+	s := defaultSettings()
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return newErrReadFailed()
+	}
+
+	l, ok := findLicense(licenses, result.Remaining[0])
+	if !ok {
+		return newErrCannotFindLicense()
+	}
+	if err := ensureFetched(s, &l); err != nil {
+		return err
+	}
+	l = l.withFullInfo(s)
+
+	keyword := strings.ToLower(result.Remaining[1])
+	c, known := clauses[keyword]
+	if !known {
+		c = clause{Terms: []string{keyword}}
+	}
+
+	printed := 0
+	for _, section := range sections(l.Body) {
+		paragraphs := section.excerpt(c.Terms, n)
+		if paragraphs == nil {
+			continue
+		}
+		if printed > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("== %s\n\n", section.Title)
+		fmt.Println(strings.Join(paragraphs, "\n\n"))
+		printed++
+	}
+
+	if printed == 0 {
+		return newErrClauseNotFound(l.Key, keyword)
+	}
+
+	if c.Rule != "" {
+		fmt.Printf("\n%s\n", describeRule(&l, c.Rule))
+	}
+
+	return nil
+}
@@ -1,54 +1,106 @@
 package base
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"gopkg.in/nishanths/simpleflag.v1"
 	"io"
 	"os"
-	"strconv"
+	"regexp"
 	"strings"
 	"text/template"
-	"time"
 )
 
+// renderOption holds the values available to license templates,
+// and the copyright format to render the copyright notice with,
+// if not the template's own.
 type renderOption struct {
-	Year string
-	Name string
+	Year   string
+	Name   string
+	Email  string
+	Org    string
+	Suffix []string
+
+	SPDXID      string
+	LicenseURL  string
+	LicenseName string
+
+	copyright string
 }
 
+// omittedYear stands in for the year while rendering without one,
+// so that the space or comma around it can be removed with it.
+const omittedYear = "\x00year\x00"
+
+var omittedYearRx = regexp.MustCompile(`[ \t]*\x00year\x00,?`)
+
+// renderTemplate renders the template with the values in o. Without
+// a year, the year is left out of the copyright notice, along with
+// the space or comma that separates it, as in "Copyright (c) Alice".
 func renderTemplate(t *template.Template, o *renderOption, w io.Writer) error {
-	return t.ExecuteTemplate(w, t.Name(), o)
-}
+	// the template is shared, so replace the notice in a copy
+	if o.copyright != "" && t.Lookup(copyrightTemplateName) != nil {
+		c, err := t.Clone()
+		if err != nil {
+			return err
+		}
+		if _, err := c.New(copyrightTemplateName).Parse(o.copyright); err != nil {
+			return err
+		}
+		t = c
+	}
 
-// Generate parses arguments and outputs the selected license.
-// Generate returns a non-nil error if it is unable to do so successfully.
-func Generate(args []string) error {
-	if len(args) < 1 {
-		return newErrExpectedLicenseName()
+	if o.Year != "" {
+		return t.ExecuteTemplate(w, t.Name(), o)
 	}
 
-	// use stdout as default writer for now, until
-	// specfied in the args
-	w := os.Stdout
+	withYear := *o
+	withYear.Year = omittedYear
 
-	// arguments values
-	var name, year, filename, licenseKey string
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, t.Name(), &withYear); err != nil {
+		return err
+	}
+	_, err := w.Write(omittedYearRx.ReplaceAll(b.Bytes(), nil))
+	return err
+}
 
-	// start looking for the default name
-	// to use on the license, in case we need it
-	nameCh := make(chan string, 1)
-	go func(ch chan string) {
-		ch <- getName()
-	}(nameCh)
+// findLicense returns the license whose key or name
+// matches arg, ignoring case.
+func findLicense(licenses []License, arg string) (License, bool) {
+	lowercasedArg := strings.ToLower(arg)
+	for _, license := range licenses {
+		if strings.ToLower(license.Key) == lowercasedArg || strings.ToLower(license.Name) == lowercasedArg {
+			return license, true
+		}
+	}
+	return License{}, false
+}
 
-	defer func() {
-		close(nameCh)
-	}()
+// GenerateCommand is "license <license>": it parses the arguments
+// into options for Generate, and generates the license they name.
+func GenerateCommand(args []string) error {
+	if len(args) < 1 {
+		return newErrExpectedLicenseName()
+	}
 
 	// parse arguments
 	generateFlagSet := simpleflag.NewFlagSet("generate")
 	generateFlagSet.Add("name", []string{"--name", "-name", "-n"}, false)
 	generateFlagSet.Add("year", []string{"--year", "-year", "-y"}, false)
+	generateFlagSet.Add("year-policy", []string{"--year-policy", "-year-policy"}, false)
+	generateFlagSet.Add("email", []string{"--email", "-email"}, false)
+	generateFlagSet.Add("org", []string{"--org", "-org"}, false)
+	generateFlagSet.Add("copyright", []string{"--copyright", "-copyright"}, false)
 	generateFlagSet.Add("output", []string{"--output", "-output", "-o"}, false)
+	generateFlagSet.Add("rights", []string{"--rights-reserved", "-rights-reserved", "-r"}, true)
+	generateFlagSet.Add("suffix", []string{"--suffix", "-suffix", "-s"}, false)
+	generateFlagSet.Add("project", []string{"--project", "-project", "-p"}, true)
+	generateFlagSet.Add("upgrade", []string{"--upgrade", "-upgrade"}, true)
+	generateFlagSet.Add("lock", []string{"--lock", "-lock"}, true)
+	generateFlagSet.Add("header", []string{"--header", "-header"}, true)
 	result, err := generateFlagSet.Parse(args)
 
 	// exit early if there is an error
@@ -61,71 +113,282 @@ func Generate(args []string) error {
 		return newErrBadFlagSyntax(result.BadFlags[0])
 	}
 
-	// normalize:
-
-	// 1. name
+	var opts []Option
 	if n, exists := result.Values["name"]; exists {
-		name = n
-	} else {
-		name = <-nameCh
+		opts = append(opts, WithName(n))
 	}
-
-	// 2. year
 	if y, exists := result.Values["year"]; exists {
-		year = y
-	} else {
-		year = strconv.Itoa(time.Now().Year())
+		opts = append(opts, WithYear(y))
+	}
+	if p, exists := result.Values["year-policy"]; exists {
+		opts = append(opts, WithYearPolicy(p))
+	}
+	if e, exists := result.Values["email"]; exists {
+		opts = append(opts, WithEmail(e))
+	}
+	if o, exists := result.Values["org"]; exists {
+		opts = append(opts, WithOrg(o))
+	}
+	if c, exists := result.Values["copyright"]; exists {
+		opts = append(opts, WithCopyright(c))
+	}
+	if o, exists := result.Values["output"]; exists {
+		opts = append(opts, WithOutput(o))
+	}
+	if _, exists := result.Values["rights"]; exists {
+		opts = append(opts, WithRightsReserved())
+	}
+	if s, exists := result.Values["suffix"]; exists {
+		opts = append(opts, WithSuffix(s))
+	}
+	if _, exists := result.Values["project"]; exists {
+		opts = append(opts, WithProject())
+	}
+	if _, exists := result.Values["upgrade"]; exists {
+		opts = append(opts, WithUpgrade())
+	}
+	if _, exists := result.Values["lock"]; exists {
+		opts = append(opts, WithLock())
+	}
+	if _, exists := result.Values["header"]; exists {
+		opts = append(opts, WithHeader())
 	}
-
-	// 3. filename
-	filename = result.Values["output"]
 
 	// get locally available licenses
-	licenses, err := getLocalList()
+	licenses, err := getLocalList(defaultSettings())
 	if err != nil {
 		return newErrReadFailed()
 	}
 
 	// find license key from remaining args
-search:
 	for _, arg := range result.Remaining {
-		for _, license := range licenses {
-			lowercasedArg := strings.ToLower(arg)
-			if strings.ToLower(license.Key) == lowercasedArg || strings.ToLower(license.Name) == lowercasedArg {
-				licenseKey = license.Key
-				break search
-			}
+		if license, ok := findLicense(licenses, arg); ok {
+			return Generate(license.Key, opts...)
 		}
 	}
 
-	if licenseKey == "" {
+	return newErrCannotFindLicense()
+}
+
+// Generate outputs the license with the key or name, by default
+// to stdout. Generate returns a non-nil error if it is unable to
+// do so successfully.
+func Generate(license string, opts ...Option) error {
+	s, done := useOptions(opts)
+	defer done()
+
+	w := s.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+
+	// values for the license
+	var name, year, filename, licenseKey string
+	var suffix []string
+	var project *ecosystem
+
+	// start looking for the default name
+	// to use on the license, in case we need it
+	nameCh := make(chan string, 1)
+	if s.Name == "" {
+		go func(ch chan string) {
+			ch <- getName()
+		}(nameCh)
+	}
+
+	// get locally available licenses
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return newErrReadFailed()
+	}
+
+	selected, ok := findLicense(licenses, license)
+	if !ok {
 		return newErrCannotFindLicense()
 	}
+	licenseKey = selected.Key
+
+	// after a minimal update, the license may not be local yet
+	if err := ensureFetched(s, &selected); err != nil {
+		return err
+	}
 
-	tmpl, err := readTemplate(licenseKey)
+	// the index lacks some details, such as the
+	// license URL, that the full information has
+	selected = selected.withFullInfo(s)
+
+	// switch a deprecated SPDX identifier to its successor if asked to
+	if s.Upgrade && selected.replacements() != nil {
+		successor := selected.upgrade(licenses)
+		if successor.Key != selected.Key {
+			if err := ensureFetched(s, &successor); err != nil {
+				return err
+			}
+			successor = successor.withFullInfo(s)
+		}
+		selected, licenseKey = successor, successor.Key
+	}
+
+	// normalize, preferring options, then settings
+	// for this license, then the general defaults:
+
+	// 1. name
+	if s.Name != "" {
+		name = s.Name
+	} else if n := getLicenseSetting(licenseKey, "name"); n != "" {
+		name = n
+	} else {
+		name = <-nameCh
+	}
+
+	// 2. filename, following the project's conventions if asked to
+	filename = s.Output
+
+	if s.Project {
+		project = detectEcosystem(".")
+		if filename == "" {
+			filename = project.filename(".", &selected)
+		}
+	}
+
+	// 3. year, or the year policy's, which looks
+	// at the existing license files for the first year
+	if s.Year != "" {
+		year = s.Year
+	} else if y := getLicenseSetting(licenseKey, "year"); y != "" {
+		year = y
+	} else {
+		policy, err := yearPolicy(licenseKey, s.YearPolicy, "--year-policy")
+		if err != nil {
+			return err
+		}
+		var existing []string
+		if filename != "" {
+			existing = append(existing, filename)
+		}
+		year = policyYear(policy, append(existing, licenseFilesIn(".")...))
+	}
+
+	if s.Lock && filename == "" {
+		return newErrLockWithoutOutput()
+	}
+
+	// a header goes at the top of source files, so
+	// it is not a project's license file
+	if s.Header && project != nil {
+		return newErrIncompatibleFlags("--header", "--project")
+	}
+	if s.Header && s.Lock {
+		return newErrIncompatibleFlags("--header", "--lock")
+	}
+
+	// 4. extra lines after the copyright notice
+	if s.RightsReserved {
+		suffix = append(suffix, allRightsReserved)
+	}
+	if len(s.Suffix) > 0 {
+		suffix = append(suffix, s.Suffix...)
+	} else if line := getLicenseSetting(licenseKey, "suffix"); line != "" {
+		suffix = append(suffix, line)
+	} else if line := getSuffix(); line != "" {
+		suffix = append(suffix, line)
+	}
+
+	// 5. the copyright notice's format, and the values
+	// that only copyright formats are likely to use
+	format, err := copyrightFormat(licenseKey, s.Copyright, "--copyright")
+	if err != nil {
+		return err
+	}
+
+	email := firstNonEmpty(s.Email, getLicenseSetting(licenseKey, "email"))
+	if email == "" && format != "" {
+		email = getEmail()
+	}
+	org := firstNonEmpty(s.Org, getLicenseSetting(licenseKey, "org"))
+
+	readTmpl, tmplName := readTemplate, licenseKey+".tmpl"
+	if s.Header {
+		readTmpl, tmplName = readHeaderTemplate, licenseKey+headerTemplateSuffix
+	}
+	tmpl, err := readTmpl(s, licenseKey)
 
 	if err != nil {
-		return newErrLoadingTemplate(licenseKey + ".tmpl")
+		return newErrLoadingTemplate(tmplName)
+	}
+
+	// point out a deprecated SPDX identifier where --upgrade
+	// would change something: where the identifier shows, or
+	// when there is a successor license
+	if r := selected.replacements(); r != nil && !s.Upgrade {
+		if s.Project || s.Lock || usesSpdxID(tmpl) || strings.Contains(format, ".SPDXID") || selected.upgrade(licenses).Key != selected.Key {
+			fmt.Fprintf(os.Stderr, "license: %s is a deprecated SPDX identifier, replaced by %s\n", selected.SpdxID, strings.Join(r, " or "))
+			fmt.Fprintf(os.Stderr, "license: use \"--upgrade\" to use %s instead\n", r[0])
+		}
 	}
 
 	o := &renderOption{
-		Name: name,
-		Year: year,
+		Name:        name,
+		Year:        year,
+		Email:       email,
+		Org:         org,
+		Suffix:      suffix,
+		SPDXID:      selected.SpdxID,
+		LicenseURL:  selected.HtmlUrl,
+		LicenseName: selected.Name,
+		copyright:   format,
+	}
+
+	// read what the lock file needs before anything is written
+	var lock *lockFile
+	if s.Lock {
+		if lock, err = readProvenance(s, filename, &selected); err != nil {
+			return err
+		}
 	}
 
 	// create the file since we are close to succeeding
+	var before string
+	var f *os.File
 	if filename != "" {
 		var err error
-		if w, err = os.Create(filename); err != nil {
+		before = snapshot(filename)
+		if f, err = os.Create(filename); err != nil {
 			return newErrWriteFileFailed(filename)
 		}
+		defer f.Close()
+		w = f
 	}
 
 	// execute template on file
-	if err := renderTemplate(tmpl, o, w); err != nil {
-		os.Remove(filename)
+	hash := sha256.New()
+	if err := renderTemplate(tmpl, o, io.MultiWriter(w, hash)); err != nil {
+		if f != nil {
+			f.Close()
+			os.Remove(filename)
+			recordChange(s, filename, before, "")
+		}
 		return newErrExecutingTemplate(tmpl)
 	}
 
+	if f != nil {
+		if err := f.Close(); err != nil {
+			os.Remove(filename)
+			recordChange(s, filename, before, "")
+			return newErrWriteFileFailed(filename)
+		}
+		recordChange(s, filename, before, hex.EncodeToString(hash.Sum(nil)))
+	}
+
+	if lock != nil {
+		if err := writeProvenance(s, lock, hex.EncodeToString(hash.Sum(nil))); err != nil {
+			return err
+		}
+	}
+
+	if project != nil {
+		return updateProjectManifest(s, ".", project, &selected)
+	}
+
 	return nil
 }
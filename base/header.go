@@ -0,0 +1,66 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// headerTemplateSuffix ends the names of header templates, which are
+// kept next to the license templates, such as "apache-2.0.header.tmpl".
+// A header is the short notice to put at the top of source files.
+const headerTemplateSuffix = ".header.tmpl"
+
+// writeHeaderTemplate writes the header template for the license,
+// which needs its full information, to the templates directory.
+func writeHeaderTemplate(l *License, templatesPath string) error {
+	p := filepath.Join(templatesPath, l.Key+headerTemplateSuffix)
+
+	if err := ioutil.WriteFile(p, []byte(headerTemplateString(l)), perm); err != nil {
+		return newErrWriteFileFailed(p)
+	}
+
+	return nil
+}
+
+// addHeaderTemplates writes header templates for the licenses in the
+// data and custom directories under root, from their raw information,
+// without fetching anything.
+func addHeaderTemplates(root string) error {
+	for _, dir := range []string{DataDirectory, CustomDirectory} {
+		rawPath := filepath.Join(root, dir, RawDirectory)
+		templatesPath := filepath.Join(root, dir, TemplatesDirectory)
+
+		files, err := ioutil.ReadDir(rawPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return newErrReadInputFailed(rawPath)
+		}
+
+		for _, f := range files {
+			if !strings.HasSuffix(f.Name(), ".json") {
+				continue
+			}
+
+			content, err := ioutil.ReadFile(filepath.Join(rawPath, f.Name()))
+			if err != nil {
+				return newErrReadInputFailed(filepath.Join(rawPath, f.Name()))
+			}
+
+			l, err := jsonToLicense(content)
+			if err != nil {
+				return newErrDeserializeFailed(content)
+			}
+			l.Key = strings.TrimSuffix(f.Name(), ".json")
+
+			if err := writeHeaderTemplate(&l, templatesPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -8,15 +8,36 @@ type helpLine struct {
 }
 
 func (l *helpLine) String() string {
-	return fmt.Sprintf("%s%-14s%s", indent, l.Left, l.Right)
+	return fmt.Sprintf("%s%-24s%s", indent, l.Left, l.Right)
 }
 
 func printCommands() {
 	fmt.Println("Additional commands:")
 	for _, c := range []helpLine{
-		{"ls", "list locally available license names"},
+		{"ls", "list locally available license names, in columns on wide terminals"},
+		{"ls -1", "list locally available license names in a single column"},
 		{"ls-remote", "list remote license names"},
+		{"ls-remote --limit <n>", "list remote license names a page at a time, with --page <p>"},
 		{"update", "update local licenses to latest remote versions"},
+		{"update --repair", "re-fetch only missing or broken local licenses"},
+		{"update --minimal", "fetch only the list of licenses; add --prefetch to fetch popular ones in the background"},
+		{"prefetch --stop", "stop fetching licenses in the background"},
+		{"update --prune", "also remove licenses no longer available remotely"},
+		{"changes --since <date>", "summarize upstream license changes since a date, by default a week ago"},
+		{"explain <key> <clause>", "print what a license says about a clause, such as patent"},
+		{"classify <file>", "identify the license in a file, or stdin with \"-\""},
+		{"relicense <from> <to>", "switch the project in this directory to another license"},
+		{"licenses-of <module>", "identify the licenses of a Go module, such as foo/bar@v1.2.3"},
+		{"import-tree <dir>", "register unknown license files in a directory as custom licenses"},
+		{"which [<dir>]", "infer the project's license from all its signals and report conflicts"},
+		{"annotate [<dir>]", "add SPDX-License-Identifier lines to source files, or preview with --dry-run"},
+		{"test-template <file>", "render a template with --vars <yaml> and compare it to --golden <file>"},
+		{"apply [<targets>]", "render every output in license.targets.yaml, all or nothing; --bench prints the throughput"},
+		{"undo", "revert the file changes of the last command that changed files"},
+		{"sources", "list the sources licenses are fetched from"},
+		{"sources add <n> <url>", "add a source; also: sources remove|enable|disable <name>"},
+		{"doctor", "check local licenses and each source they are fetched from"},
+		{"schema [<command>]", "print the JSON Schema of a command's --json output"},
 		{"help", "show help information"},
 		{"version", "print current version"},
 	} {
@@ -37,15 +58,26 @@ func printExamples() {
 
 func printUsage() {
 	fmt.Println("Usage:")
-	fmt.Println(indent + "license [-y <year>] [-n <name>] [-o <filename>] <license-name>")
+	fmt.Println(indent + "license [-y <year>] [-n <name>] [-o <filename>] [-r] [-s <line>] [-p] <license-name>")
 }
 
 func printOptions() {
 	fmt.Println("Options:")
 	for _, c := range []helpLine{
 		{"-y, --year", "year on the license"},
+		{"--year-policy <policy>", "year without --year: current, first, range (first-current), or none"},
 		{"-n, --name", "name on the license"},
 		{"-o, --output", "filename to save license"},
+		{"-r, --rights-reserved", "append \"All rights reserved.\" to the copyright notice"},
+		{"-s, --suffix", "append a custom line to the copyright notice"},
+		{"--copyright <format>", "template for the copyright notice line, such as \"Copyright (c) {{.Year}}, {{.Org}}\""},
+		{"--email, --org", "values for {{.Email}} and {{.Org}} in templates and --copyright"},
+		{"-p, --project", "follow the project's license file and manifest conventions"},
+		{"--upgrade", "use the successor of a deprecated SPDX identifier"},
+		{"--header", "print the license's short header for source files instead"},
+		{"--lock", "record how the license file was generated in " + LockFile},
+		{"--timeout <duration>", "give up on network access after a duration, such as 30s; goes first"},
+		{"-C, --chdir <dir>", "run as if started in dir; goes first"},
 	} {
 		fmt.Println(&c)
 	}
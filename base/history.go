@@ -0,0 +1,152 @@
+package base
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// catalogSnapshot records the state of the upstream catalog of licenses
+// at a point in time: the SHA-256 of the text of each license, by key.
+// Each update appends one to the history file, so that changes to the
+// catalog can be summarized later.
+type catalogSnapshot struct {
+	Time     time.Time         `json:"time"`
+	Licenses map[string]string `json:"licenses"`
+}
+
+func newCatalogSnapshot() *catalogSnapshot {
+	return &catalogSnapshot{Time: time.Now().UTC(), Licenses: make(map[string]string)}
+}
+
+// add records the text of the license in the full information JSON.
+func (s *catalogSnapshot) add(key string, content []byte) error {
+	l, err := jsonToLicense(content)
+	if err != nil {
+		return err
+	}
+	s.Licenses[key] = sha256Hex([]byte(l.Body))
+	return nil
+}
+
+// historyPath returns the path to the history file.
+func (s *settings) historyPath() (string, error) {
+	root, err := s.storePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, HistoryFile), nil
+}
+
+// recordSnapshot appends the snapshot to the history file.
+func recordSnapshot(s *settings, snapshot *catalogSnapshot) error {
+	p, err := s.historyPath()
+	if err != nil {
+		return newErrCannotLocateHomeDir()
+	}
+
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return newErrSerializeFailed(snapshot)
+	}
+
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return newErrWriteFileFailed(p)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return newErrWriteFileFailed(p)
+	}
+
+	return nil
+}
+
+// readHistory returns the recorded snapshots, oldest first.
+func readHistory(s *settings) ([]catalogSnapshot, error) {
+	p, err := s.historyPath()
+	if err != nil {
+		return nil, newErrCannotLocateHomeDir()
+	}
+
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, newErrReadInputFailed(p)
+	}
+	defer f.Close()
+
+	var history []catalogSnapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var snapshot catalogSnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			return nil, newErrDeserializeFailed(scanner.Bytes())
+		}
+		history = append(history, snapshot)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, newErrReadInputFailed(p)
+	}
+
+	return history, nil
+}
+
+// fetchCatalog fetches the index and the text of every license,
+// and returns a snapshot of the upstream catalog.
+func fetchCatalog(ctx context.Context, s *settings) (*catalogSnapshot, error) {
+	serialized, _, err := fetchIndex(ctx, s)
+	if ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+	if err != nil {
+		return nil, fetchError(err)
+	}
+
+	licenses, err := jsonToList(serialized)
+	if err != nil {
+		return nil, newErrDeserializeFailed(serialized)
+	}
+
+	snapshot := newCatalogSnapshot()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+
+	wg.Add(len(licenses))
+	for i := range licenses {
+		go func(l *License) {
+			defer wg.Done()
+			content, err := l.fetchFullInfo(ctx, s)
+			if err == nil {
+				mu.Lock()
+				if snapshot.add(l.Key, content) != nil && firstErr == nil {
+					firstErr = newErrDeserializeFailed(content)
+				}
+				mu.Unlock()
+				return
+			}
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = newErrFetchFailed()
+			}
+			mu.Unlock()
+		}(&licenses[i])
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+
+	return snapshot, firstErr
+}
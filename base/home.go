@@ -0,0 +1,93 @@
+package base
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// systemStorePaths returns the license directories that an image or a
+// package can install licenses into for every user, in order.
+func systemStorePaths() []string {
+	if runtime.GOOS == "windows" {
+		if p := os.Getenv("ProgramData"); p != "" {
+			return []string{filepath.Join(p, "license")}
+		}
+		return nil
+	}
+	return []string{"/usr/local/share/license", "/usr/share/license"}
+}
+
+var fallbackStore struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+// privateTempDir returns a directory in the temporary directory for
+// the current user, creating it if needed. Anyone can create files in
+// the temporary directory, so the directory is only used if it is not
+// a symlink, belongs to the current user, and no one else can use it.
+func privateTempDir() (string, error) {
+	name := "license"
+	if uid := os.Getuid(); uid >= 0 {
+		name += "-" + strconv.Itoa(uid)
+	}
+	p := filepath.Join(os.TempDir(), name)
+
+	if err := os.Mkdir(p, 0700); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+
+	info, err := os.Lstat(p)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || !isPrivate(info) {
+		return "", fmt.Errorf("%s is not a private directory of the current user", p)
+	}
+
+	return p, nil
+}
+
+// fallbackStorePath returns the license directory to use without a
+// home directory, as in scratch containers without HOME: the first
+// system directory that has licenses, which is enough to generate
+// them, otherwise a directory in the user's cache directory or, without
+// one, a private directory in the temporary directory, which an update
+// fills like ~/.license. Either way, a warning is printed, once, since
+// settings and history may not last there, and a system directory is
+// usually read-only.
+func fallbackStorePath() (string, error) {
+	fallbackStore.once.Do(func() {
+		for _, p := range systemStorePaths() {
+			if pathExists(filepath.Join(p, DataDirectory)) {
+				fallbackStore.path = p
+				break
+			}
+		}
+		if fallbackStore.path == "" {
+			if cache, err := os.UserCacheDir(); err == nil {
+				fallbackStore.path = filepath.Join(cache, "license")
+			} else {
+				fallbackStore.path, fallbackStore.err = privateTempDir()
+			}
+		}
+		if fallbackStore.err != nil {
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "license: cannot locate home directory, using %s\n", fallbackStore.path)
+		fmt.Fprintf(os.Stderr, "license: set %s to choose another directory\n", LicenseHomeEnvVariable)
+	})
+	return fallbackStore.path, fallbackStore.err
+}
+
+// HasLocalData returns true if the local licenses have been fetched.
+func HasLocalData() bool {
+	p, err := defaultSettings().dataPath()
+	return err == nil && pathExists(p)
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package base
+
+import (
+	"os"
+)
+
+// isPrivate returns true, since the owner of a file cannot be
+// determined on this platform. On Windows, the temporary directory
+// belongs to the user already.
+func isPrivate(info os.FileInfo) bool {
+	return true
+}
@@ -0,0 +1,16 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package base
+
+import (
+	"os"
+	"syscall"
+)
+
+// isPrivate returns true if the file belongs to the current user,
+// and other users have no permissions on it.
+func isPrivate(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid() && info.Mode().Perm()&0077 == 0
+}
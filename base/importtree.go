@@ -0,0 +1,173 @@
+package base
+
+import (
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// reuseLicensesDirectory is the directory in which REUSE
+	// compliant projects keep one file per license.
+	reuseLicensesDirectory = "LICENSES"
+
+	licenseRefPrefix    = "LicenseRef-"
+	maxCustomNameLength = 60
+)
+
+var customKeyRx = regexp.MustCompile("[^a-z0-9.-]+")
+
+// isLicenseTreeFile returns true if the file at path, relative to
+// the root of the tree, looks like it holds a license.
+func isLicenseTreeFile(path string) bool {
+	if licenseFileRx.MatchString(filepath.Base(path)) {
+		return true
+	}
+	return filepath.Base(filepath.Dir(path)) == reuseLicensesDirectory
+}
+
+// customLicenseFor returns a custom license for a license text found at
+// path, relative to the root of the tree. REUSE files are named after
+// their license, like LICENSES/LicenseRef-acme.txt; other license files
+// are named after the directory they are in, like third_party/foo/LICENSE.
+func customLicenseFor(path, text string, taken map[string]bool) *License {
+	var base string
+	if filepath.Base(filepath.Dir(path)) == reuseLicensesDirectory {
+		base = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	} else {
+		base = filepath.Base(filepath.Dir(path))
+	}
+	base = strings.Trim(customKeyRx.ReplaceAllString(strings.ToLower(strings.TrimPrefix(base, licenseRefPrefix)), "-"), "-.")
+	if base == "" {
+		base = "custom"
+	}
+	key := base
+	for i := 2; taken[key]; i++ {
+		key = base + "-" + strconv.Itoa(i)
+	}
+
+	name := key
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			name = line
+			break
+		}
+	}
+	if r := []rune(name); len(r) > maxCustomNameLength {
+		name = string(r[:maxCustomNameLength])
+	}
+
+	return &License{
+		Key:    key,
+		Name:   name,
+		SpdxID: licenseRefPrefix + key,
+		Body:   text,
+	}
+}
+
+// ImportTree scans the directory named in the arguments for license
+// files, such as a REUSE LICENSES directory or a third_party tree, and
+// registers the license texts it does not recognize as custom licenses.
+func ImportTree(args []string) error {
+	flagSet := simpleflag.NewFlagSet("import-tree")
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	if len(result.Remaining) != 1 {
+		return newErrExpectedDirectory()
+	}
+	root := result.Remaining[0]
+
+	o, err := parseClassifyOption(nil)
+	if err != nil {
+		return err
+	}
+
+	s := defaultSettings()
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return newErrReadFailed()
+	}
+
+	taken := make(map[string]bool)
+	for _, l := range licenses {
+		taken[l.Key] = true
+	}
+
+	_, asJSON := result.Values["json"]
+	summary := newBulkSummary("import-tree", "scanned", "registered")
+
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return newErrReadInputFailed(p)
+		}
+
+		rel, _ := filepath.Rel(root, p)
+		if info.IsDir() || !isLicenseTreeFile(rel) {
+			return nil
+		}
+
+		text, err := ioutil.ReadFile(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "license: failed to read %s\n", p)
+			summary.Failed++
+			return nil
+		}
+
+		// registered licenses take part in classification,
+		// so identical texts are only registered once
+		c, err := classify(s, string(text), o.Strictness)
+		if err != nil {
+			return newErrReadFailed()
+		}
+
+		if c != nil && c.Confidence >= o.MinConfidence {
+			if !asJSON {
+				fmt.Printf("%s%-40s %s\n", indent, rel, c)
+			}
+			summary.OK++
+			return nil
+		}
+
+		l := customLicenseFor(rel, string(text), taken)
+		if err := registerCustomLicense(s, l); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			summary.Failed++
+			return nil
+		}
+
+		taken[l.Key] = true
+		if !asJSON {
+			fmt.Printf("%s%-40s registered as %s (%s)\n", indent, rel, l.Key, l.SpdxID)
+		}
+		summary.Changed++
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := summary.print(asJSON); err != nil {
+		return err
+	}
+
+	if summary.Failed > 0 {
+		return newErrImportFailed(summary.Failed)
+	}
+
+	return nil
+}
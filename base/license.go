@@ -3,10 +3,14 @@ package base
 import "fmt"
 
 // License is a representation of a License object
-// as presented in the GitHub API.
+// as presented in the GitHub API. Older versions of the API
+// list rules in Required, Permitted, and Forbidden, newer ones
+// in Conditions, Permissions, and Limitations; use Rules to
+// read them either way.
 type License struct {
 	Key            string   `json:"key"`
 	Name           string   `json:"name"`
+	SpdxID         string   `json:"spdx_id"`
 	Url            string   `json:"url"`
 	HtmlUrl        string   `json:"html_url"`
 	Featured       bool     `json:"featured"`
@@ -16,7 +20,11 @@ type License struct {
 	Required       []string `json:"required"`
 	Permitted      []string `json:"permitted"`
 	Forbidden      []string `json:"forbidden"`
+	Permissions    []string `json:"permissions,omitempty"`
+	Conditions     []string `json:"conditions,omitempty"`
+	Limitations    []string `json:"limitations,omitempty"`
 	Body           string   `json:"body"`
+	Header         string   `json:"header,omitempty"`
 }
 
 // ByLicenseKey implements sort.Interface
@@ -1,65 +1,312 @@
 package base
 
 import (
+	"encoding/json"
 	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
-func getLocalList() ([]License, error) {
-	content, err := readIndex()
+// defaultPageSize is the number of licenses per page
+// when a page is requested without a limit.
+const defaultPageSize = 20
+
+// getLocalList returns the local licenses, followed
+// by the custom licenses, if there are any.
+func getLocalList(s *settings) ([]License, error) {
+	content, err := readIndex(s)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return jsonToList(content)
-}
+	licenses, err := jsonToList(content)
+
+	if err != nil {
+		return nil, err
+	}
 
-func getRemoteList() ([]License, error) {
-	body, err := fetchIndex()
+	custom, err := getCustomList(s)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return jsonToList(body)
+	return append(licenses, custom...), nil
+}
+
+// minKeyWidth is the narrowest the key column of a list gets,
+// so that lists of short keys line up the same way everywhere.
+const minKeyWidth = 14
+
+// columnGap is the space between the columns of a multi-column list.
+const columnGap = 4
+
+// keyColumnWidth returns the width of the key column for the
+// licenses: wide enough for the longest key followed by a space.
+func keyColumnWidth(licenses []License) int {
+	w := minKeyWidth
+	for _, l := range licenses {
+		if n := utf8.RuneCountInString(l.Key) + 1; n > w {
+			w = n
+		}
+	}
+	return w
+}
+
+// padRight pads s with spaces to n columns.
+func padRight(s string, n int) string {
+	if pad := n - utf8.RuneCountInString(s); pad > 0 {
+		return s + strings.Repeat(" ", pad)
+	}
+	return s
 }
 
-// printList prints the provided list of licenses
-// after sorting them. Side-effect: the underlying
-// array for the slice is sorted.
-func printList(licenses []License) {
+// listEntryLines formats a license for a single-column list with the
+// given key column width. Names that do not fit within width columns
+// are wrapped at spaces onto lines aligned with the start of the name.
+func listEntryLines(l *License, keyWidth, width int) []string {
+	prefix := indent + padRight(l.Key, keyWidth)
+	name := "(" + l.Name + ")"
+
+	avail := width - utf8.RuneCountInString(prefix)
+	if avail < minKeyWidth || utf8.RuneCountInString(name) <= avail {
+		return []string{prefix + name}
+	}
+
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(name) {
+		if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > avail {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	lines = append(lines, line)
+
+	continuation := strings.Repeat(" ", utf8.RuneCountInString(prefix))
+	for i := range lines {
+		if i == 0 {
+			lines[i] = prefix + lines[i]
+		} else {
+			lines[i] = continuation + lines[i]
+		}
+	}
+	return lines
+}
+
+// columnLines lays out the licenses in as many columns as fit
+// within width, ordered down each column like ls. It returns nil
+// if fewer than two columns fit.
+func columnLines(licenses []License, keyWidth, width int) []string {
+	cells := make([]string, len(licenses))
+	cellWidth := 0
+	for i, l := range licenses {
+		cells[i] = padRight(l.Key, keyWidth) + "(" + l.Name + ")"
+		if n := utf8.RuneCountInString(cells[i]); n > cellWidth {
+			cellWidth = n
+		}
+	}
+
+	columns := (width - len(indent) + columnGap) / (cellWidth + columnGap)
+	if columns < 2 || len(licenses) < 2 {
+		return nil
+	}
+
+	rows := (len(cells) + columns - 1) / columns
+	lines := make([]string, rows)
+	for i, cell := range cells {
+		row, column := i%rows, i/rows
+		if column == 0 {
+			lines[row] = indent
+		} else {
+			lines[row] += strings.Repeat(" ", columnGap)
+		}
+		if (column+1)*rows+row < len(cells) {
+			cell = padRight(cell, cellWidth)
+		}
+		lines[row] += cell
+	}
+	return lines
+}
+
+// printList prints the provided list of licenses after sorting them,
+// aligned to the longest key. On a terminal wide enough for more than
+// one column, the list is printed in columns unless singleColumn is
+// true. Side-effect: the underlying array for the slice is sorted.
+func printList(licenses []License, singleColumn bool) {
 	sort.Sort(ByLicenseKey(licenses))
 
+	width := terminalWidth()
+	keyWidth := keyColumnWidth(licenses)
+
+	var lines []string
+	if !singleColumn && ttyWidth() > 0 {
+		lines = columnLines(licenses, keyWidth, width)
+	}
+	if lines == nil {
+		for i := range licenses {
+			lines = append(lines, listEntryLines(&licenses[i], keyWidth, width)...)
+		}
+	}
+
 	fmt.Print("Available licenses:\n\n")
-	for _, l := range licenses {
-		fmt.Printf("%s%-14s(%s)\n", indent, l.Key, l.Name)
+	for _, line := range lines {
+		fmt.Println(line)
 	}
 	fmt.Println()
 }
 
+// listEntry is a license in the JSON output of ls.
+type listEntry struct {
+	Key    string `json:"key"`
+	Name   string `json:"name"`
+	SpdxID string `json:"spdx_id"`
+}
+
+// printListJSON prints the provided list of licenses, sorted
+// by key, as a JSON array.
+func printListJSON(licenses []License) error {
+	sort.Sort(ByLicenseKey(licenses))
+
+	entries := []listEntry{}
+	for _, l := range licenses {
+		entries = append(entries, listEntry{l.Key, l.Name, l.SpdxID})
+	}
+
+	b, err := json.MarshalIndent(entries, "", indent)
+	if err != nil {
+		return newErrSerializeFailed(entries)
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
 // ListLocal reads the list of available local licenses
-// and prints the list.
-func ListLocal() error {
-	licenses, err := getLocalList()
+// and prints the list. With -1, the list is printed in
+// a single column even on wide terminals. With --json,
+// it is printed as a JSON array.
+func ListLocal(args []string) error {
+	flagSet := simpleflag.NewFlagSet("ls")
+	flagSet.Add("single-column", []string{"-1", "--single-column", "-single-column"}, true)
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	licenses, err := getLocalList(defaultSettings())
 
 	if err != nil {
 		return newErrReadFailed()
 	}
 
-	printList(licenses)
+	if _, exists := result.Values["json"]; exists {
+		return printListJSON(licenses)
+	}
+
+	_, singleColumn := result.Values["single-column"]
+	printList(licenses, singleColumn)
 	return nil
 }
 
-// ListRemote fetches the list of remote licenses
-// and prints the list.
-func ListRemote() error {
-	licenses, err := getRemoteList()
+// ListRemote fetches the list of remote licenses and prints each
+// license as soon as it arrives, in the order of the remote list.
+// With --limit, at most that many licenses are printed, starting at
+// the page given by --page.
+func ListRemote(args []string) error {
+	flagSet := simpleflag.NewFlagSet("ls-remote")
+	flagSet.Add("limit", []string{"--limit", "-limit"}, false)
+	flagSet.Add("page", []string{"--page", "-page"}, false)
+	result, err := flagSet.Parse(args)
 
 	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	limit, page := 0, 1
+
+	if v, exists := result.Values["limit"]; exists {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return newErrInvalidArgument("--limit", v)
+		}
+	}
+
+	if v, exists := result.Values["page"]; exists {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return newErrInvalidArgument("--page", v)
+		}
+		if limit == 0 {
+			limit = defaultPageSize
+		}
+	}
+
+	s := defaultSettings()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	body, source, err := fetchIndexStream(ctx, s)
+	if ctx.Err() != nil {
+		return contextError(ctx)
+	}
+	if err != nil {
+		return fetchError(err)
+	}
+	defer body.Close()
+
+	d := json.NewDecoder(body)
+	if t, err := d.Token(); err != nil || t != json.Delim('[') {
+		if ctx.Err() != nil {
+			return contextError(ctx)
+		}
 		return newErrFetchFailed()
 	}
 
-	printList(licenses)
+	if n := sourceNotice(s, source); n != "" {
+		fmt.Fprintln(os.Stderr, n)
+	}
+
+	width := terminalWidth()
+	start := (page - 1) * limit
+
+	fmt.Print("Available licenses:\n\n")
+	for i := 0; d.More(); i++ {
+		if limit > 0 && i >= start+limit {
+			break
+		}
+
+		var l License
+		if err := d.Decode(&l); err != nil {
+			if ctx.Err() != nil {
+				return contextError(ctx)
+			}
+			return newErrFetchFailed()
+		}
+
+		if i >= start {
+			for _, line := range listEntryLines(&l, minKeyWidth, width) {
+				fmt.Println(line)
+			}
+		}
+	}
+	fmt.Println()
+
 	return nil
 }
@@ -1,22 +1,31 @@
 package base
 
 import (
-	"github.com/mitchellh/go-homedir"
 	"io/ioutil"
-	"path"
 	"path/filepath"
 	"text/template"
 )
 
+// dataPath returns the path to the local data directory.
+func (s *settings) dataPath() (string, error) {
+	root, err := s.storePath()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(root, DataDirectory), nil
+}
+
 // read returns the contents of a filename or path relative to the data directory.
-func read(f string) ([]byte, error) {
-	home, err := homedir.Dir()
+func read(s *settings, f string) ([]byte, error) {
+	p, err := s.dataPath()
 
 	if err != nil {
 		return nil, err
 	}
 
-	contents, err := ioutil.ReadFile(filepath.Join(home, LicenseDirectory, DataDirectory, f))
+	contents, err := readStored(p, f)
 
 	if err != nil {
 		return nil, err
@@ -25,27 +34,99 @@ func read(f string) ([]byte, error) {
 	return contents, nil
 }
 
+// readLicenseFile is like read, but falls back to
+// the custom directory for files of custom licenses.
+func readLicenseFile(s *settings, f string) ([]byte, error) {
+	contents, err := read(s, f)
+
+	if err == nil {
+		return contents, nil
+	}
+
+	if contents, err := readCustom(s, f); err == nil {
+		return contents, nil
+	}
+
+	return nil, err
+}
+
 // readIndex reads the local index JSON file that has the list
 // of current local licenses.
-func readIndex() ([]byte, error) {
-	return read(IndexFile)
+func readIndex(s *settings) ([]byte, error) {
+	return read(s, IndexFile)
 }
 
 // readFullInfo reads the local full JSON information for the given license.
-func (l *License) readFullInfo() ([]byte, error) {
-	return read(filepath.Join(RawDirectory, l.Key+".json"))
+func (l *License) readFullInfo(s *settings) ([]byte, error) {
+	return readLicenseFile(s, filepath.Join(RawDirectory, l.Key+".json"))
+}
+
+// withFullInfo returns the local full information for the license,
+// or the license itself if the full information cannot be read.
+func (l License) withFullInfo(s *settings) License {
+	content, err := l.readFullInfo(s)
+	if err != nil {
+		return l
+	}
+
+	full, err := jsonToLicense(content)
+	if err != nil {
+		return l
+	}
+
+	return full
+}
+
+// Find returns the full information, including its rules, for the
+// locally available license whose key or name matches arg, ignoring case.
+func Find(arg string) (License, error) {
+	s := defaultSettings()
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return License{}, newErrReadFailed()
+	}
+
+	l, ok := findLicense(licenses, arg)
+	if !ok {
+		return License{}, newErrCannotFindLicense()
+	}
+
+	return l.withFullInfo(s), nil
 }
 
 // readTemplate reads the template data and returns a template
 // for a given license key.
-func readTemplate(key string) (*template.Template, error) {
-	home, err := homedir.Dir()
+func readTemplate(s *settings, key string) (*template.Template, error) {
+	return parseTemplateFile(s, key+".tmpl")
+}
+
+// readHeaderTemplate returns the header template for a given license key.
+func readHeaderTemplate(s *settings, key string) (*template.Template, error) {
+	return parseTemplateFile(s, key+headerTemplateSuffix)
+}
+
+// readTemplateFile reads and parses the template at the path p,
+// such as a NOTICE template that belongs to a project.
+func readTemplateFile(p string) (*template.Template, error) {
+	contents, err := ioutil.ReadFile(p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return template.New(filepath.Base(p)).Parse(withCopyrightNotice(string(contents)))
+}
+
+// parseTemplateFile parses the template in the templates directory
+// with the given name.
+func parseTemplateFile(s *settings, name string) (*template.Template, error) {
+	contents, err := readLicenseFile(s, filepath.Join(TemplatesDirectory, name))
 
 	if err != nil {
 		return nil, err
 	}
 
-	tmpl, err := template.ParseFiles(path.Join(home, LicenseDirectory, DataDirectory, TemplatesDirectory, key+".tmpl"))
+	tmpl, err := template.New(name).Parse(withCopyrightNotice(string(contents)))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package base
+
+import (
+	"os"
+)
+
+// tryLock returns true, since files cannot be locked on this
+// platform. No process is then known to hold a lock, so none
+// is ever signaled because of one.
+func tryLock(f *os.File) bool {
+	return true
+}
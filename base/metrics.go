@@ -0,0 +1,103 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"github.com/nishanths/license/logger"
+	"time"
+)
+
+// FetchMetrics describes how getting one license went during an
+// update, to tell whether a slow update is waiting on the network,
+// on the rate limit, or on the disk.
+//
+// Fetch is the time spent on requests, not counting Wait, the time
+// spent waiting for the rate limit to lift. Write is the time spent
+// turning the response into files. Retries counts the requests made
+// after the first, either because of the rate limit or to try another
+// source. CacheHit is true if the license was kept from the local copy
+// instead of fetched.
+//
+// A nil *FetchMetrics records nothing, so that fetches outside
+// of an update need not keep metrics.
+type FetchMetrics struct {
+	Key      string        `json:"key"`
+	Bytes    int64         `json:"bytes"`
+	Fetch    time.Duration `json:"fetch_ns"`
+	Wait     time.Duration `json:"rate_limit_wait_ns"`
+	Write    time.Duration `json:"write_ns"`
+	Retries  int           `json:"retries"`
+	CacheHit bool          `json:"cache_hit"`
+}
+
+type fetchMetricsKey struct{}
+
+// withFetchMetrics returns a context that records
+// the metrics of the requests made with it in m.
+func withFetchMetrics(ctx context.Context, m *FetchMetrics) context.Context {
+	return context.WithValue(ctx, fetchMetricsKey{}, m)
+}
+
+// fetchMetricsFrom returns the metrics that requests made
+// with ctx are recorded in, or nil.
+func fetchMetricsFrom(ctx context.Context) *FetchMetrics {
+	m, _ := ctx.Value(fetchMetricsKey{}).(*FetchMetrics)
+	return m
+}
+
+func (m *FetchMetrics) addWait(d time.Duration) {
+	if m != nil {
+		m.Wait += d
+	}
+}
+
+func (m *FetchMetrics) addRetry() {
+	if m != nil {
+		m.Retries++
+	}
+}
+
+// fetched records a fetch of n bytes that started at start.
+func (m *FetchMetrics) fetched(start time.Time, n int) {
+	if m != nil {
+		m.Fetch = time.Since(start) - m.Wait
+		m.Bytes = int64(n)
+	}
+}
+
+// wrote records writing files that started at start.
+func (m *FetchMetrics) wrote(start time.Time) {
+	if m != nil {
+		m.Write = time.Since(start)
+	}
+}
+
+func (m *FetchMetrics) String() string {
+	if m.CacheHit {
+		return fmt.Sprintf("%-24s kept from the local copy", m.Key)
+	}
+	return fmt.Sprintf("%-24s %7d bytes  fetch %-8v  wait %-8v  write %-8v  retries %d",
+		m.Key, m.Bytes, m.Fetch.Round(time.Millisecond), m.Wait.Round(time.Millisecond),
+		m.Write.Round(time.Millisecond), m.Retries)
+}
+
+// printFetchMetrics prints the metrics of each license with verbose
+// logging, followed by their totals. The licenses are fetched at the
+// same time, so the totals can exceed the time the update took.
+func printFetchMetrics(metrics []FetchMetrics) {
+	var fetch, wait, write time.Duration
+	var retries, hits int
+
+	for i := range metrics {
+		m := &metrics[i]
+		logger.VerbosePrintf("%s%s\n", indent, m)
+		fetch, wait, write = fetch+m.Fetch, wait+m.Wait, write+m.Write
+		retries += m.Retries
+		if m.CacheHit {
+			hits++
+		}
+	}
+
+	logger.VerbosePrintf("total: fetch %v, rate limit wait %v, write %v, %d retries, %d kept from the local copy\n",
+		fetch.Round(time.Millisecond), wait.Round(time.Millisecond), write.Round(time.Millisecond), retries, hits)
+}
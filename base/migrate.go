@@ -0,0 +1,232 @@
+package base
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/nishanths/license/logger"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// storeVersion is the version of the layout of the license directory
+// that this version of license reads and writes. Older layouts are
+// migrated to it on startup.
+const storeVersion = 3
+
+// migration upgrades the license directory at root
+// from version From to version From+1.
+type migration struct {
+	From        int
+	Description string
+	migrate     func(root string) error
+}
+
+// migrations is the list of migrations, in order. A migration may add
+// files derived from the custom directory, such as header templates, but
+// must not change or remove what is there, since it holds data that cannot
+// be fetched again.
+var migrations = []migration{
+	{0, "record checksums of the license data in " + ManifestFile, func(root string) error {
+		return writeManifest(filepath.Join(root, DataDirectory))
+	}},
+	{1, "add a header template for each license", func(root string) error {
+		if err := addHeaderTemplates(root); err != nil {
+			return err
+		}
+		return writeManifest(filepath.Join(root, DataDirectory))
+	}},
+	{2, "store license files by content, once per distinct text", func(root string) error {
+		if _, _, err := storeObjects(filepath.Join(root, DataDirectory)); err != nil {
+			return err
+		}
+		return writeManifest(filepath.Join(root, DataDirectory))
+	}},
+}
+
+// storePath returns the path to the license directory: the one set
+// with WithStore, then LICENSE_HOME, then ~/.license. Without a home
+// directory, it falls back to a fallback store; see fallbackStorePath.
+func (s *settings) storePath() (string, error) {
+	if s.Store != "" {
+		return s.Store, nil
+	}
+
+	if p := getLicenseHome(); p != "" {
+		return p, nil
+	}
+
+	home, err := homedir.Dir()
+
+	if err != nil {
+		return fallbackStorePath()
+	}
+
+	return filepath.Join(home, LicenseDirectory), nil
+}
+
+// manifest lists the files in the data directory
+// by path relative to it, with their SHA-256 checksums.
+type manifest struct {
+	Version int               `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// writeManifest writes the manifest of the data directory at p.
+func writeManifest(p string) error {
+	m := manifest{Version: storeVersion, Files: make(map[string]string)}
+	manifestPath := filepath.Join(p, ManifestFile)
+
+	err := filepath.Walk(p, func(f string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || f == manifestPath {
+			return nil
+		}
+
+		contents, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(p, f)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(contents)
+		m.Files[filepath.ToSlash(rel)] = hex.EncodeToString(sum[:])
+		return nil
+	})
+
+	if err != nil {
+		return newErrReadInputFailed(p)
+	}
+
+	b, err := json.MarshalIndent(&m, "", indent)
+	if err != nil {
+		return newErrSerializeFailed(m)
+	}
+
+	if err := ioutil.WriteFile(manifestPath, append(b, '\n'), perm); err != nil {
+		return newErrWriteFileFailed(manifestPath)
+	}
+
+	return nil
+}
+
+// readStoreVersion returns the layout version of the license directory
+// at root. Directories from before versioning have no version file and
+// are version 0.
+func readStoreVersion(root string) (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(root, VersionFile))
+
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, newErrReadInputFailed(filepath.Join(root, VersionFile))
+	}
+
+	v, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, newErrDeserializeFailed(b)
+	}
+
+	return v, nil
+}
+
+// writeStoreVersion records the layout version of the license directory.
+func writeStoreVersion(root string, v int) error {
+	p := filepath.Join(root, VersionFile)
+
+	if err := ioutil.WriteFile(p, []byte(strconv.Itoa(v)+"\n"), perm); err != nil {
+		return newErrWriteFileFailed(p)
+	}
+
+	return nil
+}
+
+// logMigration appends a line describing a migration to the migration log.
+func logMigration(root string, m *migration) {
+	f, err := os.OpenFile(filepath.Join(root, MigrationLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "%s migrated from version %d to %d: %s\n", time.Now().UTC().Format(time.RFC3339), m.From, m.From+1, m.Description)
+}
+
+// isWritableDir returns true if files can be created in the directory at p.
+func isWritableDir(p string) bool {
+	f, err := ioutil.TempFile(p, ".write-test-")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// Migrate upgrades the license directory to the current layout, one
+// version at a time, recording each step in the migration log. The
+// version is saved after every step, so an interrupted migration
+// resumes where it stopped. Migrate does nothing if there are no
+// local licenses yet; the next update creates them in the current layout.
+// A read-only license directory is reported and left in its layout.
+func Migrate() error {
+	root, err := defaultSettings().storePath()
+	if err != nil {
+		return newErrCannotLocateHomeDir()
+	}
+
+	if !pathExists(filepath.Join(root, DataDirectory)) {
+		return nil
+	}
+
+	v, err := readStoreVersion(root)
+	if err != nil {
+		return err
+	}
+
+	if v > storeVersion {
+		return newErrStoreTooNew(v)
+	}
+
+	// a read-only directory, such as a system directory used as the
+	// fallback store, is read in the layout it has
+	if v < storeVersion && !isWritableDir(root) {
+		fmt.Fprintf(os.Stderr, "license: %s is read-only, not migrating it from version %d to %d\n", root, v, storeVersion)
+		return nil
+	}
+
+	for i := range migrations {
+		m := &migrations[i]
+		if m.From < v {
+			continue
+		}
+
+		logger.VerbosePrintf("migrating local licenses to version %d: %s...\n", m.From+1, m.Description)
+
+		if err := m.migrate(root); err != nil {
+			return err
+		}
+
+		if err := writeStoreVersion(root, m.From+1); err != nil {
+			return err
+		}
+
+		logMigration(root, m)
+	}
+
+	return nil
+}
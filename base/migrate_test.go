@@ -0,0 +1,112 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeTestStore writes a license directory of the given layout
+// version at root, with a license in the data and custom directories.
+func writeTestStore(t *testing.T, root string, version int) {
+	files := map[string]string{
+		"data/raw/mit.json":    `{"key": "mit", "spdx_id": "MIT", "name": "MIT License", "body": "MIT"}`,
+		"data/tmpl/mit.tmpl":   "MIT",
+		"custom/raw/foo.json":  `{"key": "foo", "name": "Foo License", "body": "Foo"}`,
+		"custom/tmpl/foo.tmpl": "Foo",
+	}
+	if version > 0 {
+		files[VersionFile] = strconv.Itoa(version) + "\n"
+	}
+
+	for name, contents := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	testcases := []struct {
+		name       string
+		version    int
+		noData     bool
+		migrations int
+		wantErr    bool
+	}{
+		{"before versioning", 0, false, 3, false},
+		{"without objects", 2, false, 1, false},
+		{"current", storeVersion, false, 0, false},
+		{"too new", storeVersion + 1, false, 0, true},
+		{"no local licenses", 0, true, 0, false},
+	}
+
+	defer os.Setenv(LicenseHomeEnvVariable, os.Getenv(LicenseHomeEnvVariable))
+
+	for _, tc := range testcases {
+		root, err := ioutil.TempDir("", "license-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(root)
+
+		if !tc.noData {
+			writeTestStore(t, root, tc.version)
+		}
+		os.Setenv(LicenseHomeEnvVariable, root)
+
+		err = Migrate()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if tc.wantErr || tc.noData {
+			if _, err := os.Stat(filepath.Join(root, MigrationLogFile)); !os.IsNotExist(err) {
+				t.Errorf("%s: migrated", tc.name)
+			}
+			continue
+		}
+
+		v, err := readStoreVersion(root)
+		if err != nil || v != storeVersion {
+			t.Errorf("%s: got version %d, %v, want %d", tc.name, v, err, storeVersion)
+		}
+
+		log, _ := ioutil.ReadFile(filepath.Join(root, MigrationLogFile))
+		if got := strings.Count(string(log), "\n"); got != tc.migrations {
+			t.Errorf("%s: got %d migrations, want %d:\n%s", tc.name, got, tc.migrations, log)
+		}
+		if tc.migrations == 0 {
+			continue
+		}
+
+		// the data directory is stored by content, with a manifest
+		data := filepath.Join(root, DataDirectory)
+		for _, f := range []string{RefsFile, ManifestFile} {
+			if !pathExists(filepath.Join(data, f)) {
+				t.Errorf("%s: no %s", tc.name, f)
+			}
+		}
+		if pathExists(filepath.Join(data, RawDirectory, "mit.json")) {
+			t.Errorf("%s: data/raw/mit.json not replaced by an object", tc.name)
+		}
+
+		// custom licenses are kept, with header templates added
+		// if the store was from before header templates
+		custom := filepath.Join(root, CustomDirectory)
+		if b, err := ioutil.ReadFile(filepath.Join(custom, TemplatesDirectory, "foo.tmpl")); err != nil || string(b) != "Foo" {
+			t.Errorf("%s: custom template changed: %q, %v", tc.name, b, err)
+		}
+		wantHeader := tc.version < 2
+		if got := pathExists(filepath.Join(custom, TemplatesDirectory, "foo"+headerTemplateSuffix)); got != wantHeader {
+			t.Errorf("%s: got custom header template %v, want %v", tc.name, got, wantHeader)
+		}
+	}
+}
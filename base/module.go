@@ -0,0 +1,203 @@
+package base
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// licenseFileRx matches the names of files that commonly hold a license.
+var licenseFileRx = regexp.MustCompile(`(?i)^(licen[sc]e|copying|unlicense)([.-].*)?$`)
+
+// moduleInfo is the subset of "go mod download -json" output we use.
+type moduleInfo struct {
+	Path    string
+	Version string
+	Dir     string
+	Error   string
+}
+
+// ModuleLicense is a license file found in a module.
+type ModuleLicense struct {
+	Path string `json:"path"`
+	*Classification
+}
+
+// ModuleLicenses lists the licenses found in a module.
+type ModuleLicenses struct {
+	Module   string          `json:"module"`
+	Version  string          `json:"version"`
+	Licenses []ModuleLicense `json:"licenses"`
+}
+
+// downloadModule downloads a Go module, if it is not already
+// in the module cache, and returns information about it.
+func downloadModule(ctx context.Context, module string) (*moduleInfo, error) {
+	if !strings.Contains(module, "@") {
+		module += "@latest"
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "mod", "download", "-json", module)
+	cmd.Stdout = &stdout
+	runErr := cmd.Run()
+
+	if ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+
+	var info moduleInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		return nil, newErrDownloadModuleFailed(module, runErr)
+	}
+
+	if info.Error != "" {
+		return nil, newErrDownloadModuleFailed(module, info.Error)
+	}
+
+	return &info, nil
+}
+
+// findModuleLicenses classifies the license files at
+// the top level of the module's directory.
+func findModuleLicenses(s *settings, info *moduleInfo, o *classifyOption) (*ModuleLicenses, error) {
+	result := &ModuleLicenses{
+		Module:   info.Path,
+		Version:  info.Version,
+		Licenses: []ModuleLicense{},
+	}
+
+	entries, err := ioutil.ReadDir(info.Dir)
+	if err != nil {
+		return nil, newErrReadInputFailed(info.Dir)
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !licenseFileRx.MatchString(e.Name()) {
+			continue
+		}
+
+		p := filepath.Join(info.Dir, e.Name())
+		text, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, newErrReadInputFailed(p)
+		}
+
+		c, err := classify(s, string(text), o.Strictness)
+		if err != nil {
+			return nil, newErrReadFailed()
+		}
+
+		if c == nil || c.Confidence < o.MinConfidence {
+			c = nil
+		}
+		result.Licenses = append(result.Licenses, ModuleLicense{p, c})
+	}
+
+	return result, nil
+}
+
+// has returns true if any of the module's license
+// files is the license named by arg.
+func (m *ModuleLicenses) has(arg string) bool {
+	for _, l := range m.Licenses {
+		if l.Classification != nil && l.is(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// LicensesOf downloads the Go module named in the arguments, such as
+// "github.com/foo/bar@v1.2.3", and prints the license of each
+// license file found in it.
+//
+// With --check, LicensesOf returns an error unless one of the module's
+// license files is the given license. With --quiet, nothing at all is
+// printed, so that the result is conveyed only by the exit code.
+func LicensesOf(args []string) error {
+	quiet, err := licensesOfCommand(args)
+	if quiet {
+		return silence(err)
+	}
+	return err
+}
+
+func licensesOfCommand(args []string) (quiet bool, err error) {
+	flagSet := simpleflag.NewFlagSet("licenses-of")
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	flagSet.Add("check", []string{"--check", "-check"}, false)
+	flagSet.Add("quiet", []string{"--quiet", "-quiet", "-q"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return false, newErrParsingArguments()
+	}
+
+	_, quiet = result.Values["quiet"]
+
+	if len(result.BadFlags) > 0 {
+		return quiet, newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	if len(result.Remaining) != 1 {
+		return quiet, newErrExpectedModule()
+	}
+
+	o, err := parseClassifyOption(nil)
+	if err != nil {
+		return quiet, err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	info, err := downloadModule(ctx, result.Remaining[0])
+	if err != nil {
+		return quiet, err
+	}
+
+	licenses, err := findModuleLicenses(defaultSettings(), info, o)
+	if err != nil {
+		return quiet, err
+	}
+
+	if want, exists := result.Values["check"]; exists && !licenses.has(want) {
+		return quiet, newErrCheckFailed(want, licenses.Module)
+	}
+
+	// a module without license files is an error in every output mode
+	if len(licenses.Licenses) == 0 {
+		return quiet, newErrNoLicenseFiles(licenses.Module + "@" + licenses.Version)
+	}
+
+	if quiet {
+		return quiet, nil
+	}
+
+	if _, exists := result.Values["json"]; exists {
+		b, err := json.MarshalIndent(licenses, "", indent)
+		if err != nil {
+			return quiet, newErrSerializeFailed(licenses)
+		}
+		fmt.Println(string(b))
+		return quiet, nil
+	}
+
+	for _, l := range licenses.Licenses {
+		if l.Classification == nil {
+			fmt.Printf("unknown\t%s\n", l.Path)
+		} else {
+			fmt.Printf("%s\t%s\n", l.Classification, l.Path)
+		}
+	}
+
+	return quiet, nil
+}
@@ -0,0 +1,179 @@
+package base
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The files of fetched licenses, their raw information and templates,
+// are stored by the SHA-256 of their contents in the objects directory,
+// so that identical texts under several keys are stored once. The refs
+// file maps the path of each file, relative to the data directory, such
+// as "tmpl/mit.tmpl", to its object.
+//
+// Files written outside of an update, such as licenses fetched when
+// first used, are kept as they are until the next update stores them
+// as objects. A file as it is takes precedence over its object.
+
+// storedDirectories are the directories of the data directory
+// whose files are stored as objects.
+var storedDirectories = []string{RawDirectory, TemplatesDirectory}
+
+// refsCache holds the refs of data directories read so far,
+// by path, since reading a license reads several files.
+var refsCache = struct {
+	sync.Mutex
+	refs map[string]map[string]string
+}{refs: make(map[string]map[string]string)}
+
+// readRefs returns the refs of the data directory at p,
+// which are empty if its files are not stored as objects.
+func readRefs(p string) (map[string]string, error) {
+	refsCache.Lock()
+	defer refsCache.Unlock()
+
+	if refs, ok := refsCache.refs[p]; ok {
+		return refs, nil
+	}
+
+	refs := make(map[string]string)
+	b, err := ioutil.ReadFile(filepath.Join(p, RefsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &refs); err != nil {
+			return nil, err
+		}
+	}
+
+	refsCache.refs[p] = refs
+	return refs, nil
+}
+
+// forgetRefs drops the cached refs, for when
+// a data directory is replaced.
+func forgetRefs() {
+	refsCache.Lock()
+	defer refsCache.Unlock()
+	refsCache.refs = make(map[string]map[string]string)
+}
+
+// readStored returns the contents of the file f, relative to the data
+// directory at p, whether stored as it is or as an object. The contents
+// of an object are checked against its name, so a corrupted object is an
+// error like a missing file, and is fetched again by a repair.
+func readStored(p, f string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(p, f))
+	if !os.IsNotExist(err) {
+		return contents, err
+	}
+
+	refs, rerr := readRefs(p)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	sum, ok := refs[filepath.ToSlash(f)]
+	if !ok {
+		return nil, err
+	}
+
+	contents, err = ioutil.ReadFile(filepath.Join(p, ObjectsDirectory, sum))
+	if err != nil {
+		return nil, err
+	}
+
+	if sha256Hex(contents) != sum {
+		return nil, newErrCorruptedObject(sum)
+	}
+
+	return contents, nil
+}
+
+// storeObjects stores the files in the data directory at p as objects,
+// and removes objects that no file refers to any more. It returns the
+// number of files and the number of objects they are stored in.
+func storeObjects(p string) (files, objects int, err error) {
+	refs, err := readRefs(p)
+	if err != nil {
+		return 0, 0, newErrReadInputFailed(filepath.Join(p, RefsFile))
+	}
+
+	objectsPath := filepath.Join(p, ObjectsDirectory)
+	if err := os.MkdirAll(objectsPath, perm); err != nil {
+		return 0, 0, newErrCreateDirFailed(objectsPath)
+	}
+
+	var stored []string
+	for _, dir := range storedDirectories {
+		entries, err := ioutil.ReadDir(filepath.Join(p, dir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, 0, newErrReadInputFailed(filepath.Join(p, dir))
+		}
+
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+
+			f := filepath.Join(dir, e.Name())
+			contents, err := ioutil.ReadFile(filepath.Join(p, f))
+			if err != nil {
+				return 0, 0, newErrReadInputFailed(filepath.Join(p, f))
+			}
+
+			sum := sha256Hex(contents)
+			objectPath := filepath.Join(objectsPath, sum)
+			if !pathExists(objectPath) {
+				if err := ioutil.WriteFile(objectPath, contents, perm); err != nil {
+					return 0, 0, newErrWriteFileFailed(objectPath)
+				}
+			}
+
+			refs[filepath.ToSlash(f)] = sum
+			stored = append(stored, filepath.Join(p, f))
+		}
+	}
+
+	b, err := json.MarshalIndent(refs, "", indent)
+	if err != nil {
+		return 0, 0, newErrSerializeFailed(refs)
+	}
+
+	// write the refs before removing the files they replace,
+	// so that an interruption leaves every file readable
+	refsPath := filepath.Join(p, RefsFile)
+	if err := ioutil.WriteFile(refsPath, append(b, '\n'), perm); err != nil {
+		return 0, 0, newErrWriteFileFailed(refsPath)
+	}
+
+	for _, f := range stored {
+		if err := os.Remove(f); err != nil {
+			return 0, 0, newErrRemovePathFailed(f)
+		}
+	}
+
+	// remove the objects of texts that are gone
+	used := make(map[string]bool)
+	for _, sum := range refs {
+		used[sum] = true
+	}
+	entries, err := ioutil.ReadDir(objectsPath)
+	if err != nil {
+		return 0, 0, newErrReadInputFailed(objectsPath)
+	}
+	for _, e := range entries {
+		if !used[e.Name()] {
+			os.Remove(filepath.Join(objectsPath, e.Name()))
+		}
+	}
+
+	return len(refs), len(used), nil
+}
@@ -0,0 +1,243 @@
+package base
+
+import (
+	"context"
+	"github.com/nishanths/license/logger"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Option configures a call to Bootstrap or Generate, for programs that
+// use license as a library. The command line builds its options from
+// its flags, so everything it can do is also reachable with options.
+type Option func(*settings)
+
+// settings are the values that options set. The zero value of each
+// field means the command line's default.
+type settings struct {
+	// for every call
+	Sources     []string
+	Concurrency int
+	Client      *http.Client
+	Store       string
+	Logger      io.Writer
+
+	// for Bootstrap
+	Repair   bool
+	Prune    bool
+	Minimal  bool
+	Progress io.Writer
+
+	// for Generate
+	Name           string
+	Email          string
+	Org            string
+	Year           string
+	YearPolicy     string
+	Copyright      string
+	Suffix         []string
+	RightsReserved bool
+	Header         bool
+	Output         string
+	Writer         io.Writer
+	Project        bool
+	Lock           bool
+	Upgrade        bool
+}
+
+// WithSource fetches licenses from the source at url, which serves them
+// in the same layout as the GitHub API, instead of the sources in the
+// sources file and the environment. Sources given in several options
+// are tried in order.
+func WithSource(url string) Option {
+	return func(s *settings) { s.Sources = append(s.Sources, url) }
+}
+
+// WithConcurrency fetches at most n licenses at a time.
+// By default, all of them are fetched at once.
+func WithConcurrency(n int) Option {
+	return func(s *settings) { s.Concurrency = n }
+}
+
+// WithHTTPClient makes requests with c instead of a default client.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *settings) { s.Client = c }
+}
+
+// WithStore keeps licenses, settings, and history in the directory at
+// p instead of ~/.license.
+func WithStore(p string) Option {
+	return func(s *settings) { s.Store = p }
+}
+
+// WithLogger prints messages, including the ones the command line only
+// prints with --verbose, to w instead of stdout. Use ioutil.Discard to
+// print none.
+func WithLogger(w io.Writer) Option {
+	return func(s *settings) { s.Logger = w }
+}
+
+// WithRepair makes Bootstrap repair the local licenses, fetching only
+// the ones that are missing or corrupted, instead of updating them all.
+func WithRepair() Option {
+	return func(s *settings) { s.Repair = true }
+}
+
+// WithPrune makes Bootstrap remove licenses that are no longer
+// available upstream, instead of keeping them.
+func WithPrune() Option {
+	return func(s *settings) { s.Prune = true }
+}
+
+// WithMinimal makes Bootstrap fetch only the index of licenses. The
+// licenses themselves are fetched when first used.
+func WithMinimal() Option {
+	return func(s *settings) { s.Minimal = true }
+}
+
+// WithProgress writes Bootstrap's progress to w as newline-delimited
+// JSON events.
+func WithProgress(w io.Writer) Option {
+	return func(s *settings) { s.Progress = w }
+}
+
+// WithName sets the name on the license.
+func WithName(name string) Option {
+	return func(s *settings) { s.Name = name }
+}
+
+// WithEmail sets the email address for {{.Email}} in templates and
+// copyright formats. By default, it is the one in git config.
+func WithEmail(email string) Option {
+	return func(s *settings) { s.Email = email }
+}
+
+// WithOrg sets the organization for {{.Org}} in templates
+// and copyright formats.
+func WithOrg(org string) Option {
+	return func(s *settings) { s.Org = org }
+}
+
+// WithCopyright replaces the copyright notice line of the license
+// with a template of its own, such as "Copyright (c) {{.Year}}, {{.Org}}".
+func WithCopyright(format string) Option {
+	return func(s *settings) { s.Copyright = format }
+}
+
+// WithYear sets the year on the license.
+func WithYear(year string) Option {
+	return func(s *settings) { s.Year = year }
+}
+
+// WithYearPolicy decides the year on the license, when there is no
+// year otherwise: "current", "first" for the year the project was
+// first published, "range" for the years since, or "none".
+func WithYearPolicy(policy string) Option {
+	return func(s *settings) { s.YearPolicy = policy }
+}
+
+// WithSuffix adds a line after the copyright notice.
+func WithSuffix(line string) Option {
+	return func(s *settings) { s.Suffix = append(s.Suffix, line) }
+}
+
+// WithRightsReserved adds "All rights reserved." after the copyright
+// notice, before any other lines.
+func WithRightsReserved() Option {
+	return func(s *settings) { s.RightsReserved = true }
+}
+
+// WithHeader generates the license's header for source files
+// instead of its text.
+func WithHeader() Option {
+	return func(s *settings) { s.Header = true }
+}
+
+// WithOutput writes the license to the file at p.
+func WithOutput(p string) Option {
+	return func(s *settings) { s.Output = p }
+}
+
+// WithWriter writes the license to w, when there is no output file.
+// By default, it is written to stdout.
+func WithWriter(w io.Writer) Option {
+	return func(s *settings) { s.Writer = w }
+}
+
+// WithProject follows the conventions of the project in the current
+// directory for the output file, and records the license in its manifest.
+func WithProject() Option {
+	return func(s *settings) { s.Project = true }
+}
+
+// WithLock records how the output file was generated in LockFile,
+// next to it: the license key, source, and checksums of the template
+// and the file.
+func WithLock() Option {
+	return func(s *settings) { s.Lock = true }
+}
+
+// WithUpgrade generates the successor of a deprecated license instead.
+func WithUpgrade() Option {
+	return func(s *settings) { s.Upgrade = true }
+}
+
+// httpClient returns the client for requests.
+func (s *settings) httpClient() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return &http.Client{}
+}
+
+// callMu lets one call with options run at a time, since calls
+// share the undo journal of the package.
+var callMu sync.Mutex
+
+// useOptions returns the settings that opts make, holding callMu
+// until done is called, which the caller must do before returning.
+func useOptions(opts []Option) (s *settings, done func()) {
+	callMu.Lock()
+
+	s = defaultSettings()
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, callMu.Unlock
+}
+
+// defaultSettings returns the settings of a call without options,
+// such as the commands of the command line.
+func defaultSettings() *settings {
+	return &settings{}
+}
+
+// logger returns the logger set with WithLogger, or nil
+// for the package's logger.
+func (s *settings) logger() *logger.Logger {
+	if s.Logger == nil {
+		return nil
+	}
+	return logger.New(s.Logger)
+}
+
+type loggerKey struct{}
+
+// withLogger returns a context whose calls print their messages
+// to l. The logger goes with the call, rather than in the settings,
+// so that commands running at the same time keep their own output.
+func withLogger(ctx context.Context, l *logger.Logger) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// loggerFrom returns the logger of the call that ctx belongs to,
+// or nil for the package's logger.
+func loggerFrom(ctx context.Context) *logger.Logger {
+	l, _ := ctx.Value(loggerKey{}).(*logger.Logger)
+	return l
+}
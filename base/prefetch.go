@@ -0,0 +1,247 @@
+package base
+
+import (
+	"context"
+	"github.com/nishanths/license/logger"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prefetchInterval is the pause between the licenses fetched in the
+// background, so that prefetching uses little of the rate limit and
+// of the network while the user is working.
+const prefetchInterval = 2 * time.Second
+
+// prefetchStopTimeout is how long stopping the prefetch waits
+// for it to finish writing the license it is fetching.
+const prefetchStopTimeout = 5 * time.Second
+
+// popularLicenses are the licenses most projects use, most used first.
+// They are prefetched before the other featured licenses.
+var popularLicenses = []string{
+	"mit", "apache-2.0", "gpl-3.0", "bsd-3-clause", "bsd-2-clause",
+	"isc", "mpl-2.0", "unlicense", "lgpl-3.0", "agpl-3.0", "gpl-2.0",
+}
+
+// prefetchPidPath returns the path to the file that holds the process
+// ID of the background prefetch. The prefetch locks the file while it
+// runs.
+func (s *settings) prefetchPidPath() (string, error) {
+	root, err := s.storePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, PrefetchPidFile), nil
+}
+
+// isFetched returns true if the license's files are available locally.
+func (l *License) isFetched(s *settings) bool {
+	_, err := l.readFullInfo(s)
+	return err == nil
+}
+
+// fetchOne fetches a license that a minimal update left out
+// and writes it to the data directory.
+func fetchOne(ctx context.Context, s *settings, l *License) error {
+	p, err := s.dataPath()
+	if err != nil {
+		return newErrCannotLocateHomeDir()
+	}
+
+	if _, err := writeLicense(ctx, s, l, filepath.Join(p, RawDirectory), filepath.Join(p, TemplatesDirectory)); err != nil {
+		if ctx.Err() != nil {
+			return contextError(ctx)
+		}
+		return err
+	}
+
+	return normalizeStore(p)
+}
+
+// ensureFetched fetches the license if a minimal update left it out,
+// so that it can be used right away.
+func ensureFetched(s *settings, l *License) error {
+	if l.isFetched(s) {
+		return nil
+	}
+
+	log := s.logger()
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = withLogger(ctx, log)
+
+	log.VerbosePrintf("fetching %s...\n", l.Key)
+	return fetchOne(ctx, s, l)
+}
+
+// prefetchOrder returns the licenses to prefetch: the popular licenses,
+// then the other featured ones, leaving out those already fetched.
+func prefetchOrder(s *settings, licenses []License) []License {
+	byKey := make(map[string]License)
+	for _, l := range licenses {
+		byKey[l.Key] = l
+	}
+
+	var order []License
+	queued := make(map[string]bool)
+	add := func(l License) {
+		if !queued[l.Key] && !l.isFetched(s) {
+			queued[l.Key] = true
+			order = append(order, l)
+		}
+	}
+
+	for _, key := range popularLicenses {
+		if l, ok := byKey[key]; ok {
+			add(l)
+		}
+	}
+	for _, l := range licenses {
+		if l.Featured {
+			add(l)
+		}
+	}
+
+	return order
+}
+
+// startPrefetch starts "license prefetch" in a process of its own,
+// so that it keeps going after this command exits.
+func startPrefetch() error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(self, "prefetch")
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	logger.VerbosePrintf("prefetching popular licenses in the background (process %d)...\n", cmd.Process.Pid)
+
+	// the prefetch is on its own from here
+	return cmd.Process.Release()
+}
+
+// stopPrefetch stops the background prefetch, if one is running, and
+// waits for it to exit. A running prefetch holds a lock on the pid file,
+// so the pid of one that has exited, which may belong to another process
+// by now, is never signaled.
+func stopPrefetch(s *settings) bool {
+	p, err := s.prefetchPidPath()
+	if err != nil {
+		return false
+	}
+
+	f, err := os.OpenFile(p, os.O_RDWR, 0600)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	if tryLock(f) {
+		// no prefetch is running
+		return false
+	}
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return false
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if proc.Signal(os.Interrupt) != nil && proc.Kill() != nil {
+		return false
+	}
+
+	// the lock is released as the prefetch exits
+	deadline := time.Now().Add(prefetchStopTimeout)
+	for !tryLock(f) && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+// Prefetch fetches the popular licenses that a minimal update left out,
+// one at a time with a pause between them, so that generating one of
+// them is instant. It stops when interrupted, or when stopped with
+// "license prefetch --stop", and can be resumed by running it again.
+// "license update --minimal --prefetch" runs it in the background.
+func Prefetch(args []string) error {
+	s := defaultSettings()
+
+	if len(args) == 1 && (args[0] == "--stop" || args[0] == "-stop") {
+		if !stopPrefetch(s) {
+			logger.Println("license: no prefetch is running")
+		}
+		return nil
+	}
+	if len(args) > 0 {
+		return newErrUnknownArgument(args...)
+	}
+
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return newErrReadFailed()
+	}
+
+	pidPath, err := s.prefetchPidPath()
+	if err != nil {
+		return newErrCannotLocateHomeDir()
+	}
+
+	// a newer prefetch takes over from an older one
+	stopPrefetch(s)
+
+	f, err := os.OpenFile(pidPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return newErrWriteFileFailed(pidPath)
+	}
+	defer f.Close()
+
+	if !tryLock(f) {
+		logger.VerbosePrintln("another prefetch is running")
+		return nil
+	}
+	if err := f.Truncate(0); err != nil {
+		return newErrWriteFileFailed(pidPath)
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return newErrWriteFileFailed(pidPath)
+	}
+	defer f.Truncate(0)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	for i, l := range prefetchOrder(s, licenses) {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return contextError(ctx)
+			case <-time.After(prefetchInterval):
+			}
+		}
+
+		if err := fetchOne(ctx, s, &l); err != nil {
+			return err
+		}
+		logger.VerbosePrintf("prefetched %s\n", l.Key)
+	}
+
+	return nil
+}
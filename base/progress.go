@@ -0,0 +1,69 @@
+package base
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+const progressFormatJSON = "json"
+
+// progressEvent is a single line of progress output.
+type progressEvent struct {
+	Phase   string `json:"phase"`
+	Current int    `json:"current"`
+	Total   int    `json:"total"`
+	Key     string `json:"key,omitempty"`
+}
+
+// progressReporter writes newline-delimited JSON progress events
+// for wrappers that render their own progress bars.
+// A nil *progressReporter discards all events.
+type progressReporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	totals  map[string]int
+	counts  map[string]int
+}
+
+func newProgressReporter(w io.Writer) *progressReporter {
+	return &progressReporter{
+		encoder: json.NewEncoder(w),
+		totals:  make(map[string]int),
+		counts:  make(map[string]int),
+	}
+}
+
+// start reports the beginning of a phase with total steps.
+func (p *progressReporter) start(phase string, total int) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.totals[phase] = total
+	p.counts[phase] = 0
+	p.encoder.Encode(&progressEvent{Phase: phase, Total: total})
+}
+
+// advance reports that one step of a phase, optionally
+// for the license with the given key, has completed.
+// It is safe to call from multiple goroutines.
+func (p *progressReporter) advance(phase, key string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.counts[phase]++
+	p.encoder.Encode(&progressEvent{
+		Phase:   phase,
+		Current: p.counts[phase],
+		Total:   p.totals[phase],
+		Key:     key,
+	})
+}
@@ -0,0 +1,277 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const defaultLicenseFilename = "LICENSE"
+
+// ecosystem describes the license conventions of a kind of project,
+// recognized by the presence of its manifest file.
+type ecosystem struct {
+	Name     string
+	Manifest string
+
+	// filename returns the conventional name of the file for the license.
+	filename func(dir string, l *License) string
+
+	// updateManifest, if non-nil, records the license in the manifest
+	// contents and returns the updated contents.
+	updateManifest func(dir string, contents []byte, l *License) ([]byte, error)
+}
+
+// ecosystems is the list of known ecosystems, in order of precedence.
+var ecosystems = []*ecosystem{
+	{"rust", "Cargo.toml", rustLicenseFilename, updateCargoManifest},
+	{"node", "package.json", conventionalLicenseFilename, updatePackageManifest},
+	{"go", "go.mod", conventionalLicenseFilename, nil},
+}
+
+// genericEcosystem is used when no known manifest is found.
+var genericEcosystem = &ecosystem{"generic", "", conventionalLicenseFilename, nil}
+
+// detectEcosystem returns the ecosystem of the project in dir.
+func detectEcosystem(dir string) *ecosystem {
+	for _, e := range ecosystems {
+		if pathExists(filepath.Join(dir, e.Manifest)) {
+			return e
+		}
+	}
+	return genericEcosystem
+}
+
+// pathExists returns true if the path exists.
+func pathExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+// spdxID returns the SPDX identifier of the license,
+// or an error if the license does not have one.
+func (l *License) spdxID() (string, error) {
+	if l.SpdxID == "" {
+		return "", newErrMissingSpdxID(l.Key)
+	}
+	return l.SpdxID, nil
+}
+
+func conventionalLicenseFilename(dir string, l *License) string {
+	return defaultLicenseFilename
+}
+
+// rustLicenseFilename follows the Rust convention of naming license files
+// after the license, so that dual licensed crates can ship both.
+// For example, LICENSE-MIT and LICENSE-APACHE.
+func rustLicenseFilename(dir string, l *License) string {
+	return rustLicenseFilenameForID(l.SpdxID, l.Key)
+}
+
+func rustLicenseFilenameForID(id, fallback string) string {
+	if id == "" {
+		id = fallback
+	}
+	if id == "Apache-2.0" {
+		return defaultLicenseFilename + "-APACHE"
+	}
+	return defaultLicenseFilename + "-" + strings.ToUpper(id)
+}
+
+var (
+	cargoPackageRx = regexp.MustCompile(`(?m)^\[package\][ \t]*\n`)
+	cargoSectionRx = regexp.MustCompile(`(?m)^\[`)
+	cargoLicenseRx = regexp.MustCompile(`(?m)^license[ \t]*=[ \t]*"([^"]*)"[ \t]*$`)
+	cargoKeyRx     = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]+[ \t]*=.*\n`)
+)
+
+// updateCargoManifest sets the license field of the [package] section.
+// Licenses already in the field whose license files are still in dir are
+// kept as alternatives, for example "MIT OR Apache-2.0", so that crates
+// can be multi-licensed; the others are dropped.
+func updateCargoManifest(dir string, contents []byte, l *License) ([]byte, error) {
+	id, err := l.spdxID()
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(contents)
+	loc := cargoPackageRx.FindStringIndex(s)
+	if loc == nil {
+		return nil, newErrUpdateManifestFailed("Cargo.toml")
+	}
+
+	// limit changes to the [package] section
+	start, end := loc[1], len(s)
+	if next := cargoSectionRx.FindStringIndex(s[start:]); next != nil {
+		end = start + next[0]
+	}
+	section := s[start:end]
+
+	if m := cargoLicenseRx.FindStringSubmatchIndex(section); m != nil {
+		expr := section[m[2]:m[3]]
+		section = section[:m[2]] + cargoLicenseExpression(dir, expr, id) + section[m[3]:]
+	} else {
+		// add the field after the last key of the section
+		line := fmt.Sprintf("license = %q\n", id)
+		at := 0
+		if keys := cargoKeyRx.FindAllStringIndex(section, -1); len(keys) > 0 {
+			at = keys[len(keys)-1][1]
+		}
+		section = section[:at] + line + section[at:]
+	}
+
+	return []byte(s[:start] + section + s[end:]), nil
+}
+
+// cargoLicenseExpression returns the license expression to
+// use in place of expr for a project that is adding id.
+func cargoLicenseExpression(dir, expr, id string) string {
+	var kept []string
+	for _, e := range strings.Split(expr, " OR ") {
+		if e == id {
+			return expr
+		}
+		if e != "" && pathExists(filepath.Join(dir, rustLicenseFilenameForID(e, e))) {
+			kept = append(kept, e)
+		}
+	}
+
+	return strings.Join(append(kept, id), " OR ")
+}
+
+var packageOpenRx = regexp.MustCompile(`^\{[ \t]*\n([ \t]*)`)
+
+// updatePackageManifest sets the top-level license field of package.json,
+// preserving the rest of its formatting. The legacy object form,
+// {"type": "MIT", "url": ...}, is replaced with an expression.
+func updatePackageManifest(dir string, contents []byte, l *License) ([]byte, error) {
+	id, err := l.spdxID()
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest map[string]interface{}
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return nil, newErrUpdateManifestFailed("package.json")
+	}
+
+	value, err := json.Marshal(id)
+	if err != nil {
+		return nil, newErrSerializeFailed(id)
+	}
+
+	if _, exists := manifest["license"]; exists {
+		start, end, ok := topLevelValue(contents, "license")
+		if !ok {
+			return nil, newErrUpdateManifestFailed("package.json")
+		}
+		return append(append(append([]byte{}, contents[:start]...), value...), contents[end:]...), nil
+	}
+
+	loc := packageOpenRx.FindSubmatchIndex(contents)
+	if loc == nil {
+		return nil, newErrUpdateManifestFailed("package.json")
+	}
+
+	indentation := contents[loc[2]:loc[3]]
+	field := fmt.Sprintf("\"license\": %s,\n%s", value, indentation)
+	return append(append(append([]byte{}, contents[:loc[1]]...), field...), contents[loc[1]:]...), nil
+}
+
+// topLevelValue returns the offsets of the value of key in the
+// top-level object of the JSON document in contents, skipping keys of
+// the same name in nested objects.
+func topLevelValue(contents []byte, key string) (start, end int, ok bool) {
+	depth := 0
+	for i := 0; i < len(contents); i++ {
+		switch contents[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case '"':
+			j := stringEnd(contents, i)
+			if j < 0 {
+				return 0, 0, false
+			}
+			name, colon := contents[i:j], skipSpace(contents, j)
+			i = j - 1
+
+			// at depth 1, a string followed by a colon is a key
+			if depth != 1 || colon >= len(contents) || contents[colon] != ':' {
+				continue
+			}
+			var k string
+			if json.Unmarshal(name, &k) != nil || k != key {
+				continue
+			}
+
+			start = skipSpace(contents, colon+1)
+			var raw json.RawMessage
+			if json.NewDecoder(bytes.NewReader(contents[start:])).Decode(&raw) != nil {
+				return 0, 0, false
+			}
+			return start, start + len(raw), true
+		}
+	}
+	return 0, 0, false
+}
+
+// stringEnd returns the offset just past the JSON string that starts
+// at offset i of contents, or -1 if the string is not terminated.
+func stringEnd(contents []byte, i int) int {
+	for j := i + 1; j < len(contents); j++ {
+		switch contents[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// skipSpace returns the offset of the first non-space byte
+// at or after offset i of contents.
+func skipSpace(contents []byte, i int) int {
+	for i < len(contents) && strings.IndexByte(" \t\r\n", contents[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// updateProjectManifest records the license in the manifest of the
+// project in dir, if its ecosystem has a manifest license field.
+func updateProjectManifest(s *settings, dir string, e *ecosystem, l *License) error {
+	if e.updateManifest == nil {
+		return nil
+	}
+
+	p := filepath.Join(dir, e.Manifest)
+	contents, err := ioutil.ReadFile(p)
+	if err != nil {
+		return newErrReadInputFailed(p)
+	}
+
+	updated, err := e.updateManifest(dir, contents, l)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return newErrReadInputFailed(p)
+	}
+
+	if err := writeFile(s, p, updated, info.Mode()); err != nil {
+		return newErrWriteFileFailed(p)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateCargoManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "license-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the crate still ships the MIT license
+	if err := ioutil.WriteFile(filepath.Join(dir, "LICENSE-MIT"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	apache := &License{Key: "apache-2.0", SpdxID: "Apache-2.0"}
+	isc := &License{Key: "isc", SpdxID: "ISC"}
+
+	testcases := []struct {
+		name     string
+		contents string
+		l        *License
+		want     string
+		wantErr  bool
+	}{
+		{
+			"keeps licenses with files",
+			"[package]\nname = \"x\"\nlicense = \"MIT\"\n",
+			apache,
+			"[package]\nname = \"x\"\nlicense = \"MIT OR Apache-2.0\"\n",
+			false,
+		},
+		{
+			"drops licenses without files",
+			"[package]\nname = \"x\"\nlicense = \"BSD-3-Clause OR MIT\"\n",
+			isc,
+			"[package]\nname = \"x\"\nlicense = \"MIT OR ISC\"\n",
+			false,
+		},
+		{
+			"already has the license",
+			"[package]\nlicense = \"MIT OR Apache-2.0\"\n",
+			apache,
+			"[package]\nlicense = \"MIT OR Apache-2.0\"\n",
+			false,
+		},
+		{
+			"adds the field after the last key",
+			"[package]\nname = \"x\"\nversion = \"0.1.0\"\n\n[dependencies]\nlicense = \"1.0\"\n",
+			isc,
+			"[package]\nname = \"x\"\nversion = \"0.1.0\"\nlicense = \"ISC\"\n\n[dependencies]\nlicense = \"1.0\"\n",
+			false,
+		},
+		{
+			"no package section",
+			"[workspace]\nmembers = []\n",
+			isc,
+			"",
+			true,
+		},
+		{
+			"no SPDX identifier",
+			"[package]\nname = \"x\"\n",
+			&License{Key: "custom"},
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testcases {
+		got, err := updateCargoManifest(dir, []byte(tc.contents), tc.l)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestUpdatePackageManifest(t *testing.T) {
+	isc := &License{Key: "isc", SpdxID: "ISC"}
+
+	testcases := []struct {
+		name     string
+		contents string
+		want     string
+		wantErr  bool
+	}{
+		{
+			"expression",
+			"{\n  \"name\": \"x\",\n  \"license\": \"MIT\"\n}\n",
+			"{\n  \"name\": \"x\",\n  \"license\": \"ISC\"\n}\n",
+			false,
+		},
+		{
+			"nested license keys",
+			"{\n  \"config\": {\"license\": \"x\", \"a\": [\"\\\"license\\\"\"]},\n  \"license\" : \"MIT\"\n}\n",
+			"{\n  \"config\": {\"license\": \"x\", \"a\": [\"\\\"license\\\"\"]},\n  \"license\" : \"ISC\"\n}\n",
+			false,
+		},
+		{
+			"legacy object",
+			"{\n  \"license\": {\n    \"type\": \"MIT\",\n    \"url\": \"https://example.com\"\n  },\n  \"name\": \"x\"\n}\n",
+			"{\n  \"license\": \"ISC\",\n  \"name\": \"x\"\n}\n",
+			false,
+		},
+		{
+			"no license",
+			"{\n\t\"name\": \"x\"\n}\n",
+			"{\n\t\"license\": \"ISC\",\n\t\"name\": \"x\"\n}\n",
+			false,
+		},
+		{
+			"no license on one line",
+			"{\"name\": \"x\"}\n",
+			"",
+			true,
+		},
+		{
+			"invalid",
+			"{\n  \"license\": \n",
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testcases {
+		got, err := updatePackageManifest("", []byte(tc.contents), isc)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package base
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// LockFile is the name of the file, next to generated licenses,
+// that records how each of them was generated.
+const LockFile = ".license.lock"
+
+// provenance records how a license file was generated, so that
+// later changes to the file or its template can be told apart.
+type provenance struct {
+	Key            string `json:"key"`
+	SpdxID         string `json:"spdx_id"`
+	Source         string `json:"source"`
+	TemplateSHA256 string `json:"template_sha256"`
+	ContentSHA256  string `json:"content_sha256"`
+	ToolVersion    string `json:"tool_version"`
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// lockFile is a lock file read for adding the record of a license
+// file that is about to be generated.
+type lockFile struct {
+	path    string
+	records map[string]provenance
+	name    string
+	record  provenance
+}
+
+// readProvenance reads the lock file in the same directory as the
+// license file to generate, and prepares the file's record, all but
+// the checksum of its contents. It reads everything the record needs
+// up front, so that a failure leaves the license file unwritten.
+func readProvenance(s *settings, filename string, l *License) (*lockFile, error) {
+	tmpl, err := readLicenseFile(s, filepath.Join(TemplatesDirectory, l.Key+".tmpl"))
+	if err != nil {
+		return nil, newErrLoadingTemplate(l.Key + ".tmpl")
+	}
+
+	f := &lockFile{
+		path:    filepath.Join(filepath.Dir(filename), LockFile),
+		records: make(map[string]provenance),
+		name:    filepath.Base(filename),
+		record: provenance{
+			Key:            l.Key,
+			SpdxID:         l.SpdxID,
+			Source:         l.Url,
+			TemplateSHA256: sha256Hex(tmpl),
+			ToolVersion:    applicationVersion,
+		},
+	}
+
+	if existing, err := ioutil.ReadFile(f.path); err == nil {
+		if err := json.Unmarshal(existing, &f.records); err != nil {
+			return nil, newErrDeserializeFailed(existing)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, newErrReadInputFailed(f.path)
+	}
+
+	return f, nil
+}
+
+// writeProvenance records the provenance of the generated license file
+// in the lock file, keyed by the file's name. Records for other files
+// in the lock file are kept.
+func writeProvenance(s *settings, f *lockFile, contentSHA256 string) error {
+	f.record.ContentSHA256 = contentSHA256
+	f.records[f.name] = f.record
+
+	b, err := json.MarshalIndent(f.records, "", indent)
+	if err != nil {
+		return newErrSerializeFailed(f.records)
+	}
+
+	if err := writeFile(s, f.path, append(b, '\n'), 0644); err != nil {
+		return newErrWriteFileFailed(f.path)
+	}
+
+	return nil
+}
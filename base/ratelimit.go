@@ -0,0 +1,114 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"github.com/nishanths/license/logger"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRateLimitRetries is how many times a request
+	// is retried after being rate limited.
+	maxRateLimitRetries = 5
+
+	// maxRetryAfter is the longest license waits for a rate limit
+	// to lift; past it, the request fails instead.
+	maxRetryAfter = 15 * time.Minute
+)
+
+// rateLimitGate holds back all requests until a rate limit lifts, so that
+// when one request is rate limited, the rest of the queue pauses with it
+// instead of failing one by one.
+type rateLimitGate struct {
+	mu       sync.Mutex
+	until    time.Time
+	counting bool
+}
+
+var rateLimit = &rateLimitGate{}
+
+// pause holds back requests for d from now.
+func (g *rateLimitGate) pause(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+}
+
+// wait blocks until the gate opens or ctx is done. One of the waiters
+// shows a countdown on stderr while the gate is closed, unless the
+// messages go to a logger.
+func (g *rateLimitGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	countdown := !g.counting && time.Now().Before(g.until) && !logger.IsQuiet() && loggerFrom(ctx) == nil
+	if countdown {
+		g.counting = true
+	}
+	g.mu.Unlock()
+
+	if countdown {
+		defer func() {
+			g.mu.Lock()
+			g.counting = false
+			g.mu.Unlock()
+			fmt.Fprint(os.Stderr, "\r\033[K")
+		}()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		g.mu.Lock()
+		left := time.Until(g.until)
+		g.mu.Unlock()
+
+		if left <= 0 {
+			return nil
+		}
+
+		if countdown {
+			fmt.Fprintf(os.Stderr, "\rlicense: rate limited, resuming in %v...\033[K", left.Round(time.Second))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// retryAfter returns how long to wait before retrying a request that
+// was rate limited, from the Retry-After header, either in seconds or
+// as a date, or from the time GitHub's rate limit resets. It returns
+// false if the response is not a rate limit response.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return time.Until(t), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0)), true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,397 @@
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	licenseFilenames = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "COPYING"}
+	readmeFilenames  = []string{"README", "README.md", "README.txt", "README.rst"}
+
+	// spdxIDRx matches a license identifier in an SPDX expression
+	spdxIDRx = regexp.MustCompile(`[A-Za-z0-9.+-]+`)
+
+	copyrightNoticeRx = regexp.MustCompile(`(?im)^[ \t]*copyright[ \t]*(?:\(c\)|©)?[ \t]*([0-9]{4}(?:[ \t]*[-,][ \t]*[0-9]{4})*),?[ \t]*(.*?)[ \t]*$`)
+)
+
+// relicenseSummary lists what relicensing changed,
+// and what it left for the user to change.
+type relicenseSummary struct {
+	Changed   []string
+	Unchanged []string
+}
+
+func (s *relicenseSummary) print(from, to *License) {
+	fmt.Printf("Relicensed from %s to %s.\n", from.Name, to.Name)
+
+	if len(s.Changed) > 0 {
+		fmt.Println()
+		fmt.Println("Changed:")
+		for _, c := range s.Changed {
+			fmt.Println(indent + c)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Check by hand:")
+	for _, u := range s.Unchanged {
+		fmt.Println(indent + u)
+	}
+}
+
+// findLicenseFile returns the path of the file in dir
+// that contains the given license.
+func findLicenseFile(s *settings, dir string, l *License) (string, error) {
+	candidates := licenseFilenames
+	if matches, err := filepath.Glob(filepath.Join(dir, defaultLicenseFilename+"-*")); err == nil {
+		for _, m := range matches {
+			candidates = append(candidates, filepath.Base(m))
+		}
+	}
+
+	o, err := parseClassifyOption(nil)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range candidates {
+		p := filepath.Join(dir, name)
+		text, err := ioutil.ReadFile(p)
+		if err != nil {
+			continue
+		}
+
+		c, err := classify(s, string(text), o.Strictness)
+		if err != nil {
+			return "", newErrReadFailed()
+		}
+
+		if c != nil && c.Key == l.Key && c.Confidence >= o.MinConfidence {
+			return p, nil
+		}
+	}
+
+	return "", newErrLicenseFileNotFound(l.Key)
+}
+
+// parseCopyrightNotice returns the year and name in the first
+// copyright notice of a license text, if there is one.
+func parseCopyrightNotice(text string) (year, name string, ok bool) {
+	for _, m := range copyrightNoticeRx.FindAllStringSubmatch(text, -1) {
+		if m[2] != "" && !strings.Contains(m[2], "[") {
+			return m[1], m[2], true
+		}
+	}
+	return "", "", false
+}
+
+// renderToFile renders the license template for the key to a new file.
+func renderToFile(s *settings, key string, o *renderOption, filename string) error {
+	tmpl, err := readTemplate(s, key)
+	if err != nil {
+		return newErrLoadingTemplate(key + ".tmpl")
+	}
+
+	var b bytes.Buffer
+	if err := renderTemplate(tmpl, o, &b); err != nil {
+		return newErrExecutingTemplate(tmpl)
+	}
+
+	if err := writeFile(s, filename, b.Bytes(), 0644); err != nil {
+		return newErrWriteFileFailed(filename)
+	}
+
+	return nil
+}
+
+// relicenseReadmes replaces mentions of the old license's name in the
+// project's READMEs, and lists lines that still mention its SPDX identifier.
+func relicenseReadmes(s *settings, dir string, from, to *License, summary *relicenseSummary) error {
+	var idRx *regexp.Regexp
+	if from.SpdxID != "" {
+		idRx = regexp.MustCompile(`\b` + regexp.QuoteMeta(from.SpdxID) + `\b`)
+	}
+
+	for _, name := range readmeFilenames {
+		p := filepath.Join(dir, name)
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		contents, err := ioutil.ReadFile(p)
+		if err != nil {
+			return newErrReadInputFailed(p)
+		}
+
+		text := string(contents)
+		if n := strings.Count(text, from.Name); n > 0 {
+			text = strings.Replace(text, from.Name, to.Name, -1)
+			if err := writeFile(s, p, []byte(text), info.Mode()); err != nil {
+				return newErrWriteFileFailed(p)
+			}
+			summary.Changed = append(summary.Changed, fmt.Sprintf("%s: replaced %d mention(s) of %q", name, n, from.Name))
+		}
+
+		if idRx == nil {
+			continue
+		}
+		for i, line := range strings.Split(text, "\n") {
+			if idRx.MatchString(line) {
+				summary.Unchanged = append(summary.Unchanged, fmt.Sprintf("%s:%d: mentions %q", name, i+1, from.SpdxID))
+			}
+		}
+	}
+
+	return nil
+}
+
+// relicenseSPDXTags replaces the old license's identifier in the
+// SPDX-License-Identifier lines of the project's source files, such as those
+// added by "license annotate". Directories with a license file of their own
+// are left alone, since they are under a license of their own.
+func relicenseSPDXTags(s *settings, dir string, from, to *License, summary *relicenseSummary) error {
+	n := 0
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return newErrReadInputFailed(p)
+		}
+
+		if info.IsDir() {
+			if p == dir {
+				return nil
+			}
+			if strings.HasPrefix(info.Name(), ".") || annotateSkipDirs[info.Name()] || hasLicenseFile(p) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if lineComment(p) == "" {
+			return nil
+		}
+
+		contents, err := ioutil.ReadFile(p)
+		if err != nil {
+			return newErrReadInputFailed(p)
+		}
+
+		replaced, ok := replaceSPDXTag(contents, from.SpdxID, to.SpdxID)
+		if !ok {
+			return nil
+		}
+		if err := writeFile(s, p, replaced, info.Mode()); err != nil {
+			return newErrWriteFileFailed(p)
+		}
+		n++
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		summary.Changed = append(summary.Changed, fmt.Sprintf("replaced %q in the SPDX-License-Identifier line of %d source file(s)", from.SpdxID, n))
+	}
+	return nil
+}
+
+// replaceSPDXTag replaces the identifier from with to in the expression of
+// the SPDX-License-Identifier line at the top of contents. It returns false
+// if there is no such line, or its expression does not mention from.
+func replaceSPDXTag(contents []byte, from, to string) ([]byte, bool) {
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+
+	// tags are at the top of files
+	for i := 0; i < 10 && i < len(lines); i++ {
+		m := spdxTagRx.FindSubmatchIndex(lines[i])
+		if m == nil {
+			continue
+		}
+
+		expr := lines[i][m[2]:m[3]]
+		found := false
+		expr = spdxIDRx.ReplaceAllFunc(expr, func(id []byte) []byte {
+			if string(id) != from {
+				return id
+			}
+			found = true
+			return []byte(to)
+		})
+		if !found {
+			return nil, false
+		}
+
+		var line []byte
+		line = append(line, lines[i][:m[2]]...)
+		line = append(line, expr...)
+		line = append(line, lines[i][m[3]:]...)
+		lines[i] = line
+		return bytes.Join(lines, nil), true
+	}
+
+	return nil, false
+}
+
+// Relicense switches the project in the current directory from one
+// license to another: it replaces the license file, keeping its copyright
+// notice, updates the project manifest, READMEs, and SPDX-License-Identifier
+// lines, and prints a summary of what it changed and what needs to be
+// checked by hand. It does not replace an existing file with the new
+// license file unless --force is given.
+func Relicense(args []string) error {
+	flagSet := simpleflag.NewFlagSet("relicense")
+	flagSet.Add("name", []string{"--name", "-name", "-n"}, false)
+	flagSet.Add("year", []string{"--year", "-year", "-y"}, false)
+	flagSet.Add("force", []string{"--force", "-force", "-f"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	if len(result.Remaining) != 2 {
+		return newErrExpectedLicenseName()
+	}
+
+	s := defaultSettings()
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return newErrReadFailed()
+	}
+
+	from, ok := findLicense(licenses, result.Remaining[0])
+	if !ok {
+		return newErrCannotFindLicense()
+	}
+	to, ok := findLicense(licenses, result.Remaining[1])
+	if !ok {
+		return newErrCannotFindLicense()
+	}
+	if from.Key == to.Key {
+		return newErrSameLicense(from.Key)
+	}
+	_, force := result.Values["force"]
+	if err := ensureFetched(s, &to); err != nil {
+		return err
+	}
+	from, to = from.withFullInfo(s), to.withFullInfo(s)
+
+	dir := "."
+	summary := &relicenseSummary{}
+
+	oldPath, err := findLicenseFile(s, dir, &from)
+	if err != nil {
+		return err
+	}
+
+	// keep the existing copyright notice unless told otherwise
+	text, err := ioutil.ReadFile(oldPath)
+	if err != nil {
+		return newErrReadInputFailed(oldPath)
+	}
+
+	year, name, ok := parseCopyrightNotice(string(text))
+	if !ok {
+		policy, err := yearPolicy(to.Key, "", "")
+		if err != nil {
+			return err
+		}
+		year, name = policyYear(policy, nil), getName()
+	}
+	if y, exists := result.Values["year"]; exists {
+		year = y
+	}
+	if n, exists := result.Values["name"]; exists {
+		name = n
+	}
+
+	format, err := copyrightFormat(to.Key, "", "")
+	if err != nil {
+		return err
+	}
+
+	o := &renderOption{
+		Year:        year,
+		Name:        name,
+		Email:       getLicenseSetting(to.Key, "email"),
+		Org:         getLicenseSetting(to.Key, "org"),
+		SPDXID:      to.SpdxID,
+		LicenseURL:  to.HtmlUrl,
+		LicenseName: to.Name,
+		copyright:   format,
+	}
+	if o.Email == "" && format != "" {
+		o.Email = getEmail()
+	}
+	if v := getLicenseSetting(to.Key, "suffix"); v != "" {
+		o.Suffix = []string{v}
+	} else if v := getSuffix(); v != "" {
+		o.Suffix = []string{v}
+	}
+
+	// 1. license file
+	project := detectEcosystem(dir)
+	newPath := oldPath
+	if project.Name == "rust" {
+		newPath = filepath.Join(dir, project.filename(dir, &to))
+	}
+	if newPath != oldPath && pathExists(newPath) && !force {
+		return newErrFileExists(newPath)
+	}
+
+	if err := renderToFile(s, to.Key, o, newPath); err != nil {
+		return err
+	}
+
+	if newPath != oldPath {
+		if err := removeFile(s, oldPath); err != nil {
+			return newErrRemovePathFailed(oldPath)
+		}
+		summary.Changed = append(summary.Changed, fmt.Sprintf("replaced %s with %s", filepath.Base(oldPath), filepath.Base(newPath)))
+	} else {
+		summary.Changed = append(summary.Changed, fmt.Sprintf("replaced %s", filepath.Base(newPath)))
+	}
+
+	// 2. manifest
+	if project.updateManifest != nil {
+		if err := updateProjectManifest(s, dir, project, &to); err != nil {
+			summary.Unchanged = append(summary.Unchanged, fmt.Sprintf("%s: %v", project.Manifest, err))
+		} else {
+			summary.Changed = append(summary.Changed, "updated "+project.Manifest)
+		}
+	}
+
+	// 3. READMEs
+	if err := relicenseReadmes(s, dir, &from, &to, summary); err != nil {
+		return err
+	}
+
+	// 4. SPDX-License-Identifier lines
+	if from.SpdxID != "" && to.SpdxID != "" {
+		if err := relicenseSPDXTags(s, dir, &from, &to, summary); err != nil {
+			return err
+		}
+	}
+
+	// 5. what this command does not do
+	summary.Unchanged = append(summary.Unchanged,
+		"license headers in source files, other than SPDX-License-Identifier lines",
+		"compatibility of dependency licenses with "+to.Name,
+	)
+
+	summary.print(&from, &to)
+	return nil
+}
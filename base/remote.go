@@ -1,79 +1,250 @@
 package base
 
 import (
+	"context"
+	"fmt"
 	"github.com/google/go-querystring/query"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
 const (
 	gitHubClientIDEnvVariable     = "GITHUB_CLIENT_ID"
 	gitHubClientSecretEnvVariable = "GITHUB_CLIENT_SECRET"
-	gitHubAPIBaseURL              = "https://api.github.com"
+	gitHubAPIHost                 = "api.github.com"
+	gitHubAPIBaseURL              = "https://" + gitHubAPIHost
 	gitHubAPILicensesPath         = "/licenses"
 	gitHubAPIAccept               = "application/vnd.github.drax-preview+json application/vnd.github.v3+json"
 )
 
 // fetch performs a HTTP request after appending required headers,
 // and returns the response bytes and an error, if any.
-func fetch(req *http.Request) ([]byte, error) {
+// The request is canceled if ctx is done before it completes.
+func fetch(ctx context.Context, s *settings, req *http.Request) ([]byte, error) {
+	body, err := fetchStream(ctx, s, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+// prepareRequest appends the headers that requests to the GitHub API
+// require. Requests to the GitHub API itself also get the client
+// credentials from the environment; other sources never see them.
+func prepareRequest(req *http.Request) error {
 	type option struct {
 		ClientID     string `url:"client_id"`
 		ClientSecret string `url:"client_secret"`
 	}
 
-	client := &http.Client{}
-
 	// additional http headers
 	req.Header.Add("Accept", gitHubAPIAccept)
 
-	// query string
+	if req.URL.Host != gitHubAPIHost {
+		return nil
+	}
+
+	// query string, keeping any query the URL already has
 	queryOpt := option{os.Getenv(gitHubClientIDEnvVariable), os.Getenv(gitHubClientSecretEnvVariable)}
 	queryValues, err := query.Values(queryOpt)
 	if err != nil {
+		return err
+	}
+	q := req.URL.Query()
+	for k, v := range queryValues {
+		q[k] = v
+	}
+	req.URL.RawQuery = q.Encode()
+
+	return nil
+}
+
+// fetchStream is like fetch, but returns the response body
+// for reading as it arrives. The caller must close it.
+// Requests that are rate limited are retried once the limit
+// lifts, and hold back other requests in the meantime.
+func fetchStream(ctx context.Context, s *settings, req *http.Request) (io.ReadCloser, error) {
+	client := s.httpClient()
+
+	if err := prepareRequest(req); err != nil {
 		return nil, err
 	}
-	req.URL.RawQuery = queryValues.Encode()
 
-	resp, err := client.Do(req)
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			fetchMetricsFrom(ctx).addRetry()
+		}
+
+		waitStart := time.Now()
+		if err := rateLimit.wait(ctx); err != nil {
+			return nil, err
+		}
+		fetchMetricsFrom(ctx).addWait(time.Since(waitStart))
+
+		var err error
+		resp, err = client.Do(req.WithContext(ctx))
+
+		if err != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, err
+		}
+
+		// pause the queue for a rate limit, then try again
+		d, limited := retryAfter(resp)
+		if !limited || attempt == maxRateLimitRetries || d > maxRetryAfter {
+			break
+		}
+		resp.Body.Close()
+		rateLimit.pause(d)
+	}
 
-	if resp != nil {
-		defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", req.URL.Host, resp.Status)
 	}
 
-	if err != nil {
-		return nil, err
+	return resp.Body, nil
+}
+
+// sources returns the base URLs to fetch licenses from, in the
+// order to try them: the enabled sources from the sources file,
+// by default just the GitHub API, followed by any mirrors in the
+// environment. Mirrors serve licenses in the same layout as the
+// GitHub API. Sources given with WithSource replace them all.
+func sources(s *settings) []string {
+	if len(s.Sources) > 0 {
+		return s.Sources
+	}
+
+	var urls []string
+	c, _ := readSourcesConfig(s)
+	for _, source := range c.Sources {
+		if source.Enabled {
+			urls = append(urls, source.URL)
+		}
+	}
+	return append(urls, getMirrors()...)
+}
+
+// sourceName returns the host of a source, for messages.
+func sourceName(source string) string {
+	if i := strings.Index(source, "://"); i >= 0 {
+		return source[i+3:]
+	}
+	return source
+}
+
+// fetchStreamFromSources requests p from each source in turn until
+// one of them responds successfully, and returns the response body
+// and the source that served it. The caller must close the body.
+func fetchStreamFromSources(ctx context.Context, s *settings, p string) (io.ReadCloser, string, error) {
+	lastErr := newErrNoSources()
+
+	for _, source := range sources(s) {
+		req, err := http.NewRequest("GET", source+p, nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		body, err := fetchStream(ctx, s, req)
+		if err == nil {
+			return body, source, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", err
+		}
+
+		loggerFrom(ctx).VerbosePrintf("could not fetch from %s (%v), trying next source...\n", sourceName(source), err)
+		lastErr = err
+	}
+
+	return nil, "", lastErr
+}
+
+// fetchError returns the error to report for a failure to fetch
+// from the sources: the reason if no source was enabled, otherwise
+// a general failure.
+func fetchError(err error) error {
+	if _, ok := err.(*errNoSources); ok {
+		return err
 	}
+	return newErrFetchFailed()
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// fetchFromSources is like fetchStreamFromSources, but returns
+// the response bytes.
+func fetchFromSources(ctx context.Context, s *settings, p string) ([]byte, string, error) {
+	body, source, err := fetchStreamFromSources(ctx, s, p)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return body, nil
+	defer body.Close()
+
+	b, err := ioutil.ReadAll(body)
+	return b, source, err
 }
 
-// fetchIndex performs the JSON from the GitHub API that lists
-// the available licenses.
-func fetchIndex() ([]byte, error) {
-	req, err := http.NewRequest("GET", gitHubAPIBaseURL+gitHubAPILicensesPath, nil)
+// fetchIndexFrom fetches the JSON that lists the
+// available licenses from a single source.
+func fetchIndexFrom(ctx context.Context, s *settings, source string) ([]byte, error) {
+	req, err := http.NewRequest("GET", source+gitHubAPILicensesPath, nil)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return fetch(req)
+	return fetch(ctx, s, req)
+}
+
+// fetchIndex performs the JSON from the GitHub API, or the first
+// mirror that responds, that lists the available licenses.
+// It also returns the source that served the JSON.
+func fetchIndex(ctx context.Context, s *settings) ([]byte, string, error) {
+	return fetchFromSources(ctx, s, gitHubAPILicensesPath)
+}
+
+// fetchIndexStream is like fetchIndex, but returns the response
+// body for reading as it arrives. The caller must close it.
+func fetchIndexStream(ctx context.Context, s *settings) (io.ReadCloser, string, error) {
+	return fetchStreamFromSources(ctx, s, gitHubAPILicensesPath)
 }
 
-// fetchInfo fetches the full JSON information for a license.
-func (l *License) fetchFullInfo() ([]byte, error) {
+// fetchInfo fetches the full JSON information for a license
+// from the URL in the index, falling back to the other sources.
+func (l *License) fetchFullInfo(ctx context.Context, s *settings) ([]byte, error) {
 	req, err := http.NewRequest("GET", l.Url, nil)
 
-	if err != nil {
-		return nil, err
+	if err == nil {
+		if content, err := fetch(ctx, s, req); err == nil || ctx.Err() != nil {
+			return content, err
+		}
 	}
 
-	return fetch(req)
+	fetchMetricsFrom(ctx).addRetry()
+	content, _, err := fetchFromSources(ctx, s, gitHubAPILicensesPath+"/"+l.Key)
+	return content, err
+}
+
+// sourceNotice returns a notice for the user when the data came
+// from a source other than the first one to try, or an empty string.
+func sourceNotice(s *settings, source string) string {
+	all := sources(s)
+	if source == "" || len(all) == 0 || source == all[0] {
+		return ""
+	}
+	return fmt.Sprintf("license: %s could not be reached, used %s instead", sourceName(all[0]), sourceName(source))
 }
@@ -0,0 +1,211 @@
+package base
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"text/template"
+)
+
+// Target is a license to render with RenderAll, and where to render it.
+// With Header, the license's header is rendered instead of its text.
+// With Template, the template file at that path is rendered instead;
+// Key is then optional, and fills in the license variables. With
+// Copyright, the copyright notice line is rendered from that format,
+// such as "Copyright (c) {{.Year}}, {{.Org}}", instead. Without a Name
+// or a Year, they are decided as in Generate: the name by the license's
+// name setting, then the user's name, and the year by the license's year
+// setting, then by YearPolicy, which takes the values WithYearPolicy
+// does. A nil Writer is stdout.
+type Target struct {
+	Key        string
+	Name       string
+	Year       string
+	YearPolicy string
+	Email      string
+	Org        string
+	Suffix     []string
+	Header     bool
+	Template   string
+	Copyright  string
+	Writer     io.Writer
+}
+
+// bufferPool holds buffers for rendering, so that
+// bulk rendering does not allocate one per license.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// renderer renders licenses, loading the template, information, and
+// defaults for each license key at most once.
+type renderer struct {
+	settings  *settings
+	templates map[string]*template.Template
+	licenses  map[string]License
+	names     map[string]string
+	years     map[yearKey]string
+}
+
+// yearKey identifies the year of targets without one, which
+// depends on the license key and the target's year policy.
+type yearKey struct {
+	key, policy string
+}
+
+func newRenderer(s *settings) *renderer {
+	return &renderer{
+		settings:  s,
+		templates: make(map[string]*template.Template),
+		licenses:  make(map[string]License),
+		names:     make(map[string]string),
+		years:     make(map[yearKey]string),
+	}
+}
+
+// templateName returns the name of the target's template, which
+// identifies it among the templates the renderer has loaded.
+func (t *Target) templateName() string {
+	switch {
+	case t.Template != "":
+		return t.Template
+	case t.Header:
+		return t.Key + headerTemplateSuffix
+	}
+	return t.Key + ".tmpl"
+}
+
+func (r *renderer) load(t *Target) (*template.Template, License, error) {
+	name := t.templateName()
+
+	tmpl, ok := r.templates[name]
+	if !ok {
+		var err error
+		switch {
+		case t.Template != "":
+			tmpl, err = readTemplateFile(t.Template)
+		case t.Header:
+			tmpl, err = readHeaderTemplate(r.settings, t.Key)
+		default:
+			tmpl, err = readTemplate(r.settings, t.Key)
+		}
+		if err != nil {
+			return nil, License{}, newErrLoadingTemplate(name)
+		}
+		r.templates[name] = tmpl
+	}
+
+	l, ok := r.licenses[t.Key]
+	if !ok && t.Key != "" {
+		l = License{Key: t.Key}.withFullInfo(r.settings)
+		r.licenses[t.Key] = l
+	}
+
+	return tmpl, l, nil
+}
+
+// name returns the name for targets of the license without one: the
+// license's name setting, or the user's name, as in Generate.
+func (r *renderer) name(key string) string {
+	n, ok := r.names[key]
+	if !ok {
+		if n = licenseSetting(key, "name"); n == "" {
+			n = getName()
+		}
+		r.names[key] = n
+	}
+	return n
+}
+
+// year returns the year for targets without one: the license's year
+// setting, or the year its year policy gives, as in Generate.
+func (r *renderer) year(t *Target) (string, error) {
+	k := yearKey{t.Key, t.YearPolicy}
+	y, ok := r.years[k]
+	if !ok {
+		if y = licenseSetting(t.Key, "year"); y == "" {
+			policy, err := yearPolicy(t.Key, t.YearPolicy, "YearPolicy")
+			if err != nil {
+				return "", err
+			}
+			y = policyYear(policy, licenseFilesIn("."))
+		}
+		r.years[k] = y
+	}
+	return y, nil
+}
+
+// render renders the target into a pooled buffer, then writes it
+// to the target's writer, so that a failed render writes nothing.
+func (r *renderer) render(t *Target) error {
+	tmpl, l, err := r.load(t)
+	if err != nil {
+		return err
+	}
+
+	name := t.Name
+	if name == "" {
+		name = r.name(t.Key)
+	}
+
+	year := t.Year
+	if year == "" {
+		if year, err = r.year(t); err != nil {
+			return err
+		}
+	}
+
+	w := t.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+
+	o := &renderOption{
+		Name:        name,
+		Year:        year,
+		Email:       t.Email,
+		Org:         t.Org,
+		Suffix:      t.Suffix,
+		SPDXID:      l.SpdxID,
+		LicenseURL:  l.HtmlUrl,
+		LicenseName: l.Name,
+		copyright:   t.Copyright,
+	}
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := renderTemplate(tmpl, o, buf); err != nil {
+		return newErrExecutingTemplate(tmpl)
+	}
+
+	if _, err := buf.WriteTo(w); err != nil {
+		return newErrWriteFileFailed(t.Key)
+	}
+
+	return nil
+}
+
+// RenderAll renders each target's license to its writer. Templates are
+// loaded once per license key, which makes rendering many licenses, or
+// the same license many times, efficient. RenderAll stops at the first
+// error, which it returns.
+func RenderAll(targets []Target) error {
+	return renderAll(defaultSettings(), targets)
+}
+
+func renderAll(s *settings, targets []Target) error {
+	r := newRenderer(s)
+
+	for i := range targets {
+		if err := r.render(&targets[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
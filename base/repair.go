@@ -0,0 +1,148 @@
+package base
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// isHealthy returns true if the license's raw JSON parses
+// and its templates parse.
+func (l *License) isHealthy(s *settings) bool {
+	content, err := l.readFullInfo(s)
+	if err != nil {
+		return false
+	}
+
+	if _, err := jsonToLicense(content); err != nil {
+		return false
+	}
+
+	contents, err := read(s, filepath.Join(TemplatesDirectory, l.Key+".tmpl"))
+	if err != nil {
+		return false
+	}
+
+	if _, err := template.New(l.Key).Parse(string(contents)); err != nil {
+		return false
+	}
+
+	header, err := read(s, filepath.Join(TemplatesDirectory, l.Key+headerTemplateSuffix))
+	if err != nil {
+		return false
+	}
+
+	_, err = template.New(l.Key).Parse(string(header))
+	return err == nil
+}
+
+// repairIndex fetches and writes the index file if the local
+// one is missing or cannot be parsed, and returns the licenses in it.
+func repairIndex(ctx context.Context, s *settings, progress *progressReporter, summary *BootstrapSummary, indexFilePath string) ([]License, error) {
+	// only the fetched licenses; custom licenses cannot be fetched again
+	if content, err := readIndex(s); err == nil {
+		if licenses, err := jsonToList(content); err == nil {
+			return licenses, nil
+		}
+	}
+
+	loggerFrom(ctx).VerbosePrintln("local index file is broken, fetching...")
+	progress.start("index", 1)
+
+	serialized, source, err := fetchIndex(ctx, s)
+	if ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+	if err != nil {
+		return nil, fetchError(err)
+	}
+	summary.Source = source
+	summary.Bytes += int64(len(serialized))
+
+	canonical, err := canonicalIndexJSON(serialized)
+	if err != nil {
+		return nil, newErrDeserializeFailed(serialized)
+	}
+
+	if err := checkIndex(canonical); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(indexFilePath, canonical, perm); err != nil {
+		return nil, newErrWriteFileFailed(indexFilePath)
+	}
+
+	progress.advance("index", "")
+
+	return jsonToList(canonical)
+}
+
+// repair re-fetches only the parts of the local data that are
+// missing or cannot be parsed, leaving healthy licenses untouched.
+// Repaired licenses are reported as updated in the summary.
+func repair(ctx context.Context, s *settings, progress *progressReporter) (*BootstrapSummary, error) {
+	summary := newBootstrapSummary()
+
+	p, err := s.dataPath()
+	if err != nil {
+		return summary, newErrCannotLocateHomeDir()
+	}
+
+	rawPath := filepath.Join(p, RawDirectory)
+	templatesPath := filepath.Join(p, TemplatesDirectory)
+
+	for _, d := range []string{rawPath, templatesPath} {
+		if err := os.MkdirAll(d, perm); err != nil {
+			return summary, newErrCreateDirFailed(d)
+		}
+	}
+
+	licenses, err := repairIndex(ctx, s, progress, summary, filepath.Join(p, IndexFile))
+	if err != nil {
+		return summary, err
+	}
+
+	var firstErr error
+
+	progress.start("licenses", len(licenses))
+
+	for _, l := range licenses {
+		if l.isHealthy(s) {
+			summary.kept(l.Key)
+			progress.advance("licenses", l.Key)
+			continue
+		}
+
+		loggerFrom(ctx).VerbosePrintf("repairing %s...\n", l.Key)
+
+		metrics := &FetchMetrics{Key: l.Key}
+		content, err := writeLicense(withFetchMetrics(ctx, metrics), s, &l, rawPath, templatesPath)
+		summary.Bytes += int64(len(content))
+		summary.Licenses = append(summary.Licenses, *metrics)
+		progress.advance("licenses", l.Key)
+
+		if ctx.Err() != nil {
+			return summary, contextError(ctx)
+		}
+
+		if err != nil {
+			summary.Failed = append(summary.Failed, l.Key)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		summary.Updated = append(summary.Updated, l.Key)
+	}
+
+	if err := normalizeStore(p); err != nil && firstErr == nil {
+		firstErr = err
+	}
+
+	loggerFrom(ctx).VerbosePrintln("repair complete!")
+
+	return summary, firstErr
+}
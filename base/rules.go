@@ -0,0 +1,169 @@
+package base
+
+// Rule is a permission, condition, or limitation of a license,
+// identified as in the GitHub API, such as "commercial-use".
+type Rule string
+
+// Permissions.
+const (
+	CommercialUse Rule = "commercial-use"
+	Modifications Rule = "modifications"
+	Distribution  Rule = "distribution"
+	PrivateUse    Rule = "private-use"
+	PatentUse     Rule = "patent-use"
+)
+
+// Conditions.
+const (
+	IncludeCopyright   Rule = "include-copyright"
+	DocumentChanges    Rule = "document-changes"
+	DiscloseSource     Rule = "disclose-source"
+	NetworkUseDisclose Rule = "network-use-disclose"
+	SameLicense        Rule = "same-license"
+)
+
+// Limitations.
+const (
+	TrademarkUse Rule = "trademark-use"
+	Liability    Rule = "liability"
+	Warranty     Rule = "warranty"
+)
+
+// ruleLabels are the human readable names of the known rules.
+var ruleLabels = map[Rule]string{
+	CommercialUse:      "Commercial use",
+	Modifications:      "Modification",
+	Distribution:       "Distribution",
+	PrivateUse:         "Private use",
+	PatentUse:          "Patent use",
+	IncludeCopyright:   "License and copyright notice",
+	DocumentChanges:    "State changes",
+	DiscloseSource:     "Disclose source",
+	NetworkUseDisclose: "Network use is distribution",
+	SameLicense:        "Same license",
+	TrademarkUse:       "Trademark use",
+	Liability:          "Liability",
+	Warranty:           "Warranty",
+}
+
+// Label returns the human readable name of the rule,
+// or the rule itself if it is not a known rule.
+func (r Rule) Label() string {
+	if label, ok := ruleLabels[r]; ok {
+		return label
+	}
+	return string(r)
+}
+
+// Rules groups the rules of a license.
+type Rules struct {
+	// Permissions are what the license allows.
+	Permissions []Rule
+	// Conditions are what the license requires in return.
+	Conditions []Rule
+	// Limitations are what the license does not provide.
+	Limitations []Rule
+}
+
+func hasRule(rules []Rule, r Rule) bool {
+	for _, x := range rules {
+		if x == r {
+			return true
+		}
+	}
+	return false
+}
+
+// toRules converts rules as found in the GitHub API to Rules.
+// Older responses name some limitations differently, such as
+// "no-liability" for "liability"; those are normalized.
+func toRules(lists ...[]string) []Rule {
+	var rules []Rule
+	for _, list := range lists {
+		for _, s := range list {
+			r := Rule(s)
+			switch s {
+			case "no-liability":
+				r = Liability
+			case "no-warranty":
+				r = Warranty
+			}
+			if !hasRule(rules, r) {
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules
+}
+
+// Rules returns the rules of the license. The index of licenses does
+// not include them, so l should hold the full information, such as a
+// License returned by Find.
+func (l *License) Rules() Rules {
+	return Rules{
+		Permissions: toRules(l.Permissions, l.Permitted),
+		Conditions:  toRules(l.Conditions, l.Required),
+		Limitations: toRules(l.Limitations, l.Forbidden),
+	}
+}
+
+// Permits returns true if r is one of the permissions.
+func (r Rules) Permits(rule Rule) bool {
+	return hasRule(r.Permissions, rule)
+}
+
+// Requires returns true if r is one of the conditions.
+func (r Rules) Requires(rule Rule) bool {
+	return hasRule(r.Conditions, rule)
+}
+
+// Limits returns true if r is one of the limitations.
+func (r Rules) Limits(rule Rule) bool {
+	return hasRule(r.Limitations, rule)
+}
+
+// AllowsCommercialUse returns true if the license permits commercial use.
+func (l *License) AllowsCommercialUse() bool {
+	return l.Rules().Permits(CommercialUse)
+}
+
+// AllowsModification returns true if the license permits modification.
+func (l *License) AllowsModification() bool {
+	return l.Rules().Permits(Modifications)
+}
+
+// AllowsDistribution returns true if the license permits distribution.
+func (l *License) AllowsDistribution() bool {
+	return l.Rules().Permits(Distribution)
+}
+
+// GrantsPatentRights returns true if the license expressly grants patent rights.
+func (l *License) GrantsPatentRights() bool {
+	return l.Rules().Permits(PatentUse)
+}
+
+// RequiresNotice returns true if copies must include
+// the license and copyright notice.
+func (l *License) RequiresNotice() bool {
+	return l.Rules().Requires(IncludeCopyright)
+}
+
+// RequiresSourceDisclosure returns true if the source must be made
+// available when distributing, or, for network copyleft licenses,
+// when users interact with the software over a network.
+func (l *License) RequiresSourceDisclosure() bool {
+	r := l.Rules()
+	return r.Requires(DiscloseSource) || r.Requires(NetworkUseDisclose)
+}
+
+// RequiresSameLicense returns true if modifications
+// must be released under the same license.
+func (l *License) RequiresSameLicense() bool {
+	return l.Rules().Requires(SameLicense)
+}
+
+// IsCopyleft returns true if the license requires both
+// disclosing source and keeping the same license.
+func (l *License) IsCopyleft() bool {
+	return l.RequiresSourceDisclosure() && l.RequiresSameLicense()
+}
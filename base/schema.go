@@ -0,0 +1,226 @@
+package base
+
+import (
+	"fmt"
+	"sort"
+)
+
+// schemas are the JSON Schemas of the JSON output of each command,
+// keyed by command. They are the contract for automation that reads
+// the output, so fields are only ever added to them; a field is not
+// removed or changed without a new major version.
+var schemas = map[string]string{
+	"update": `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "title": "license update --json",
+    "description": "What an update did to the local licenses.",
+    "type": "object",
+    "required": ["added", "updated", "unchanged", "failed", "stale", "removed", "source", "bytes_downloaded", "elapsed_ns", "licenses"],
+    "properties": {
+        "added": {"$ref": "#/definitions/keys", "description": "Licenses that were not available locally before."},
+        "updated": {"$ref": "#/definitions/keys", "description": "Licenses whose local copies changed."},
+        "unchanged": {"$ref": "#/definitions/keys", "description": "Licenses whose local copies did not change."},
+        "failed": {"$ref": "#/definitions/keys", "description": "Licenses that could not be fetched."},
+        "stale": {"$ref": "#/definitions/keys", "description": "Licenses no longer in the upstream index, kept from before unless --prune is given."},
+        "removed": {"$ref": "#/definitions/keys", "description": "Licenses no longer in the upstream index, removed because --prune was given."},
+        "source": {"type": "string", "description": "The base URL of the source the licenses were fetched from."},
+        "bytes_downloaded": {"type": "integer", "minimum": 0},
+        "elapsed_ns": {"type": "integer", "minimum": 0},
+        "licenses": {
+            "type": "array",
+            "description": "How getting each license went, sorted by key.",
+            "items": {
+                "type": "object",
+                "required": ["key", "bytes", "fetch_ns", "rate_limit_wait_ns", "write_ns", "retries", "cache_hit"],
+                "properties": {
+                    "key": {"type": "string"},
+                    "bytes": {"type": "integer", "minimum": 0, "description": "Bytes downloaded for the license."},
+                    "fetch_ns": {"type": "integer", "minimum": 0, "description": "Time spent on requests, not counting rate limit waits."},
+                    "rate_limit_wait_ns": {"type": "integer", "minimum": 0, "description": "Time spent waiting for the rate limit to lift."},
+                    "write_ns": {"type": "integer", "minimum": 0, "description": "Time spent writing the license's files."},
+                    "retries": {"type": "integer", "minimum": 0, "description": "Requests made after the first."},
+                    "cache_hit": {"type": "boolean", "description": "Whether the license was kept from the local copy instead of fetched."}
+                }
+            }
+        }
+    },
+    "definitions": {
+        "keys": {"type": "array", "items": {"type": "string"}}
+    }
+}`,
+
+	"ls": `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "title": "license ls --json",
+    "description": "The locally available licenses, sorted by key.",
+    "type": "array",
+    "items": {
+        "type": "object",
+        "required": ["key", "name", "spdx_id"],
+        "properties": {
+            "key": {"type": "string", "description": "The key to generate the license with."},
+            "name": {"type": "string"},
+            "spdx_id": {"type": "string", "description": "The SPDX identifier, or LicenseRef-<key> for custom licenses; empty if unknown."}
+        }
+    }
+}`,
+
+	"classify": `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "title": "license classify --json",
+    "description": "The license that best matches a text.",
+    "$ref": "#/definitions/classification",
+    "definitions": {
+        "classification": {
+            "type": "object",
+            "required": ["key", "spdx_id", "name", "confidence"],
+            "properties": {
+                "key": {"type": "string"},
+                "spdx_id": {"type": "string"},
+                "name": {"type": "string"},
+                "confidence": {"type": "number", "minimum": 0, "maximum": 1}
+            }
+        }
+    }
+}`,
+
+	"licenses-of": `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "title": "license licenses-of --json",
+    "description": "The license files of a Go module. The classification fields are absent for files that match no license.",
+    "type": "object",
+    "required": ["module", "version", "licenses"],
+    "properties": {
+        "module": {"type": "string"},
+        "version": {"type": "string"},
+        "licenses": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "required": ["path"],
+                "properties": {
+                    "path": {"type": "string"},
+                    "key": {"type": "string"},
+                    "spdx_id": {"type": "string"},
+                    "name": {"type": "string"},
+                    "confidence": {"type": "number", "minimum": 0, "maximum": 1}
+                }
+            }
+        }
+    }
+}`,
+
+	"changes": `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "title": "license changes --json",
+    "description": "The changes to the upstream catalog of licenses since a date.",
+    "type": "object",
+    "required": ["since", "baseline", "added", "removed", "reworded"],
+    "properties": {
+        "since": {"type": "string", "format": "date-time"},
+        "baseline": {"type": "string", "format": "date-time", "description": "The time of the recorded catalog the current one is compared with."},
+        "added": {"$ref": "#/definitions/changes"},
+        "removed": {"$ref": "#/definitions/changes"},
+        "reworded": {"$ref": "#/definitions/changes"}
+    },
+    "definitions": {
+        "changes": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "required": ["key", "first_seen"],
+                "properties": {
+                    "key": {"type": "string"},
+                    "first_seen": {"type": "string", "format": "date-time"}
+                }
+            }
+        }
+    }
+}`,
+
+	"which": `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "title": "license which --json",
+    "description": "The license of a project, inferred from its signals, and the signals that disagree with its license files.",
+    "type": "object",
+    "required": ["directory", "license", "signals", "conflicts"],
+    "properties": {
+        "directory": {"type": "string"},
+        "license": {"type": "string", "description": "An SPDX license expression."},
+        "signals": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "required": ["source", "licenses"],
+                "properties": {
+                    "source": {"type": "string", "description": "A file name, or \"source files\" for SPDX-License-Identifier lines."},
+                    "licenses": {"type": ["array", "null"], "items": {"type": "string"}},
+                    "detail": {"type": "string"}
+                }
+            }
+        },
+        "conflicts": {
+            "type": "array",
+            "items": {
+                "type": "object",
+                "required": ["source", "says", "expected"],
+                "properties": {
+                    "source": {"type": "string"},
+                    "says": {"type": "array", "items": {"type": "string"}},
+                    "expected": {"type": "array", "items": {"type": "string"}}
+                }
+            }
+        }
+    }
+}`,
+
+	"annotate":    fmt.Sprintf(bulkSummarySchema, "annotate", "annotate"),
+	"import-tree": fmt.Sprintf(bulkSummarySchema, "import-tree", "import-tree"),
+	"apply":       fmt.Sprintf(bulkSummarySchema, "apply", "apply"),
+}
+
+// schemaAliases maps other names of commands to their names in schemas.
+var schemaAliases = map[string]string{
+	"bootstrap": "update",
+	"list":      "ls",
+}
+
+// schemaCommands returns the commands that have a schema, sorted.
+func schemaCommands() []string {
+	var commands []string
+	for c := range schemas {
+		commands = append(commands, c)
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// Schema prints the JSON Schema of the JSON output of the command
+// named in the arguments, or lists the commands that have one.
+func Schema(args []string) error {
+	switch len(args) {
+	case 0:
+		fmt.Print("Commands with JSON output:\n\n")
+		for _, c := range schemaCommands() {
+			fmt.Println(indent + c)
+		}
+		fmt.Println()
+		return nil
+	case 1:
+	default:
+		return newErrUnknownArgument(args[1:]...)
+	}
+
+	command := args[0]
+	if c, ok := schemaAliases[command]; ok {
+		command = c
+	}
+
+	s, ok := schemas[command]
+	if !ok {
+		return newErrNoSchema(args[0])
+	}
+
+	fmt.Println(s)
+	return nil
+}
@@ -0,0 +1,143 @@
+package base
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSchemas checks each schema against the type that the command
+// marshals, so that the schemas cannot drift from the output.
+func TestSchemas(t *testing.T) {
+	testcases := []struct {
+		command string
+		output  interface{}
+	}{
+		{"update", BootstrapSummary{}},
+		{"ls", []listEntry{}},
+		{"classify", Classification{}},
+		{"licenses-of", ModuleLicenses{}},
+		{"changes", catalogChanges{}},
+		{"which", inference{}},
+		{"annotate", bulkSummary{}},
+		{"import-tree", bulkSummary{}},
+		{"apply", bulkSummary{}},
+	}
+
+	var tested []string
+	for _, tc := range testcases {
+		tested = append(tested, tc.command)
+
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(schemas[tc.command]), &schema); err != nil {
+			t.Errorf("%s: schema does not parse: %v", tc.command, err)
+			continue
+		}
+		definitions, _ := schema["definitions"].(map[string]interface{})
+		checkSchema(t, tc.command, schema, definitions, reflect.TypeOf(tc.output))
+	}
+
+	sort.Strings(tested)
+	if got := schemaCommands(); !reflect.DeepEqual(got, tested) {
+		t.Errorf("schemas for %v, tested %v", got, tested)
+	}
+}
+
+// checkSchema checks that schema describes the JSON encoding of typ:
+// that objects have a property for each field and nothing else, and
+// only require fields that are always present.
+func checkSchema(t *testing.T, path string, schema, definitions map[string]interface{}, typ reflect.Type) {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		def, ok := definitions[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: unknown definition %s", path, ref)
+			return
+		}
+		schema = def
+	}
+
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ.Kind() == reflect.Slice:
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: array without items", path)
+			return
+		}
+		checkSchema(t, path+"[]", items, definitions, typ.Elem())
+
+	case typ.Kind() == reflect.Struct && typ != reflect.TypeOf(time.Time{}):
+		properties, _ := schema["properties"].(map[string]interface{})
+		fields := jsonFields(typ)
+
+		for name := range fields {
+			if _, ok := properties[name]; !ok {
+				t.Errorf("%s: field %s is not in the schema", path, name)
+			}
+		}
+		for name, property := range properties {
+			f, ok := fields[name]
+			if !ok {
+				t.Errorf("%s: property %s is not in the output", path, name)
+				continue
+			}
+			checkSchema(t, path+"."+name, property.(map[string]interface{}), definitions, f.typ)
+		}
+
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			if f, ok := fields[r.(string)]; ok && f.omitted {
+				t.Errorf("%s: property %s is required, but can be left out", path, r)
+			}
+		}
+	}
+}
+
+type jsonField struct {
+	typ     reflect.Type
+	omitted bool // whether the field can be left out
+}
+
+// jsonFields returns the fields of the JSON encoding of the struct
+// type typ by name, including those of embedded structs.
+func jsonFields(typ reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField)
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+
+		name, options := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, options = tag[:i], tag[i:]
+		}
+
+		if f.Anonymous && name == "" {
+			embedded, pointer := f.Type, f.Type.Kind() == reflect.Ptr
+			if pointer {
+				embedded = embedded.Elem()
+			}
+			for n, ef := range jsonFields(embedded) {
+				fields[n] = jsonField{ef.typ, ef.omitted || pointer}
+			}
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = jsonField{f.Type, strings.Contains(options, "omitempty")}
+	}
+
+	return fields
+}
@@ -0,0 +1,58 @@
+package base
+
+import (
+	"context"
+	"github.com/nishanths/license/logger"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// timeout bounds the runtime of commands that use the network.
+// Zero means no limit.
+var timeout time.Duration
+
+// SetTimeout bounds the total runtime of each command that uses
+// the network to d. A zero duration removes the bound.
+func SetTimeout(d time.Duration) {
+	timeout = d
+}
+
+// interruptContext returns a context that is canceled when the process
+// receives SIGINT or SIGTERM, or when the timeout set with SetTimeout
+// elapses. Call stop to stop listening for the signals and release
+// the context.
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelTimeout := func() {}
+	if timeout > 0 {
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-ch:
+			logger.VerbosePrintln("interrupted, cleaning up...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(ch)
+		cancelTimeout()
+		cancel()
+	}
+}
+
+// contextError returns the error to report for a context that is done:
+// either the timeout elapsed or the process was interrupted.
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return newErrTimedOut(timeout)
+	}
+	return newErrInterrupted()
+}
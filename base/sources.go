@@ -0,0 +1,235 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitHubSourceName is the name of the built-in source, the GitHub API.
+const gitHubSourceName = "github"
+
+// sourceConfig is a source of licenses that the user has configured.
+// A source serves licenses in the layout of the GitHub API.
+type sourceConfig struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Enabled bool   `json:"enabled"`
+}
+
+// sourcesConfig is the contents of the sources file.
+type sourcesConfig struct {
+	Sources []sourceConfig `json:"sources"`
+}
+
+// find returns the source with the name, or nil.
+func (c *sourcesConfig) find(name string) *sourceConfig {
+	for i := range c.Sources {
+		if c.Sources[i].Name == name {
+			return &c.Sources[i]
+		}
+	}
+	return nil
+}
+
+// sourcesPath returns the path to the sources file.
+func (s *settings) sourcesPath() (string, error) {
+	root, err := s.storePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, SourcesFile), nil
+}
+
+// readSourcesConfig returns the configured sources. Without a sources
+// file, the GitHub API is the only source.
+func readSourcesConfig(s *settings) (*sourcesConfig, error) {
+	c := &sourcesConfig{Sources: []sourceConfig{{gitHubSourceName, gitHubAPIBaseURL, true}}}
+
+	p, err := s.sourcesPath()
+	if err != nil {
+		return c, newErrCannotLocateHomeDir()
+	}
+
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, newErrReadInputFailed(p)
+	}
+
+	c.Sources = nil
+	if err := json.Unmarshal(b, c); err != nil {
+		return c, newErrDeserializeFailed(b)
+	}
+
+	return c, nil
+}
+
+// write saves the sources file.
+func (c *sourcesConfig) write(s *settings) error {
+	p, err := s.sourcesPath()
+	if err != nil {
+		return newErrCannotLocateHomeDir()
+	}
+
+	b, err := json.MarshalIndent(c, "", indent)
+	if err != nil {
+		return newErrSerializeFailed(c)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), perm); err != nil {
+		return newErrCreateDirFailed(filepath.Dir(p))
+	}
+
+	if err := ioutil.WriteFile(p, append(b, '\n'), 0600); err != nil {
+		return newErrWriteFileFailed(p)
+	}
+
+	return nil
+}
+
+// validateSource checks that the URL is an HTTP or HTTPS URL of a
+// source that serves an index of licenses that can be parsed, with
+// keys that are safe to use in file names, and returns the number
+// of licenses in it.
+func validateSource(s *settings, rawURL string) (int, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return 0, newErrInvalidArgument(rawURL)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	body, err := fetchIndexFrom(ctx, s, rawURL)
+	if ctx.Err() != nil {
+		return 0, contextError(ctx)
+	}
+	if err != nil {
+		return 0, newErrInvalidSource(rawURL, err.Error())
+	}
+
+	licenses, err := jsonToList(body)
+	if err != nil || len(licenses) == 0 {
+		return 0, newErrInvalidSource(rawURL, "it does not serve a list of licenses at "+gitHubAPILicensesPath)
+	}
+
+	// its keys become file names when licenses are fetched from it
+	if err := checkIndex(body); err != nil {
+		return 0, newErrInvalidSource(rawURL, "it serves licenses with keys that are not valid file names")
+	}
+
+	return len(licenses), nil
+}
+
+func printSources(c *sourcesConfig) {
+	fmt.Print("Sources, in the order they are tried:\n\n")
+	for _, s := range c.Sources {
+		state := "enabled"
+		if !s.Enabled {
+			state = "disabled"
+		}
+		fmt.Printf("%s%-14s%-9s%s\n", indent, s.Name, state, s.URL)
+	}
+	for _, m := range getMirrors() {
+		fmt.Printf("%s%-14s%-9s%s\n", indent, "(env)", "enabled", m)
+	}
+	fmt.Println()
+}
+
+// Sources manages the sources licenses are fetched from:
+//
+//	license sources [list]
+//	license sources add <name> <url>
+//	license sources remove <name>
+//	license sources enable <name>
+//	license sources disable <name>
+//
+// Sources are tried in the order they were added, after the GitHub
+// API, followed by any mirrors in the environment. A source is only
+// added if it serves an index of licenses that can be parsed.
+func Sources(args []string) error {
+	s := defaultSettings()
+	c, err := readSourcesConfig(s)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		printSources(c)
+		return nil
+	}
+
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "add":
+		if len(args) != 2 {
+			return newErrExpectedSourceArgs(command, "<name> <url>")
+		}
+		name, rawURL := args[0], strings.TrimRight(args[1], "/")
+		if c.find(name) != nil {
+			return newErrSourceExists(name)
+		}
+
+		n, err := validateSource(s, rawURL)
+		if err != nil {
+			return err
+		}
+
+		c.Sources = append(c.Sources, sourceConfig{name, rawURL, true})
+		if err := c.write(s); err != nil {
+			return err
+		}
+		fmt.Printf("added %s, serving %d licenses\n", name, n)
+
+	case "remove", "rm":
+		if len(args) != 1 {
+			return newErrExpectedSourceArgs(command, "<name>")
+		}
+		if args[0] == gitHubSourceName {
+			return newErrRemoveBuiltinSource(args[0])
+		}
+		if c.find(args[0]) == nil {
+			return newErrUnknownSource(args[0])
+		}
+
+		var kept []sourceConfig
+		for _, source := range c.Sources {
+			if source.Name != args[0] {
+				kept = append(kept, source)
+			}
+		}
+		c.Sources = kept
+		if err := c.write(s); err != nil {
+			return err
+		}
+		fmt.Printf("removed %s\n", args[0])
+
+	case "enable", "disable":
+		if len(args) != 1 {
+			return newErrExpectedSourceArgs(command, "<name>")
+		}
+		source := c.find(args[0])
+		if source == nil {
+			return newErrUnknownSource(args[0])
+		}
+
+		source.Enabled = command == "enable"
+		if err := c.write(s); err != nil {
+			return err
+		}
+		fmt.Printf("%sd %s\n", command, args[0])
+
+	default:
+		return newErrUnknownArgument(command)
+	}
+
+	return nil
+}
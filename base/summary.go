@@ -0,0 +1,72 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// bulkSummary counts the outcomes of an operation on many files, so
+// that every such operation ends with the same kind of line, such as
+//
+//	checked 1243 files: 1200 ok, 40 annotated, 3 failed
+//
+// or, with --json, the same counts as a JSON object.
+type bulkSummary struct {
+	Operation string `json:"operation"`
+	Files     int    `json:"files"`
+	OK        int    `json:"ok"`
+	Changed   int    `json:"changed"`
+	Skipped   int    `json:"skipped"`
+	Failed    int    `json:"failed"`
+
+	// verb is the past tense of the operation for the text line,
+	// and changed describes the changed files, such as "registered".
+	verb, changed string
+}
+
+func newBulkSummary(operation, verb, changed string) *bulkSummary {
+	return &bulkSummary{Operation: operation, verb: verb, changed: changed}
+}
+
+func (s *bulkSummary) String() string {
+	line := fmt.Sprintf("%s %d files: %d ok, %d %s", s.verb, s.Files, s.OK, s.Changed, s.changed)
+	if s.Skipped > 0 {
+		line += fmt.Sprintf(", %d skipped", s.Skipped)
+	}
+	return line + fmt.Sprintf(", %d failed", s.Failed)
+}
+
+// print prints the summary line, or the JSON object with asJSON.
+func (s *bulkSummary) print(asJSON bool) error {
+	s.Files = s.OK + s.Changed + s.Skipped + s.Failed
+
+	if !asJSON {
+		fmt.Println(s)
+		return nil
+	}
+
+	b, err := json.MarshalIndent(s, "", indent)
+	if err != nil {
+		return newErrSerializeFailed(s)
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// bulkSummarySchema is the JSON Schema of the JSON output of
+// the commands that end with a bulkSummary.
+const bulkSummarySchema = `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "title": "license %s --json",
+    "description": "The outcome of an operation on many files.",
+    "type": "object",
+    "required": ["operation", "files", "ok", "changed", "skipped", "failed"],
+    "properties": {
+        "operation": {"type": "string", "const": "%s"},
+        "files": {"type": "integer", "minimum": 0, "description": "The sum of the other counts."},
+        "ok": {"type": "integer", "minimum": 0, "description": "Files that needed no change."},
+        "changed": {"type": "integer", "minimum": 0, "description": "Files that were changed, or would be with --dry-run."},
+        "skipped": {"type": "integer", "minimum": 0, "description": "Files that were left alone on purpose."},
+        "failed": {"type": "integer", "minimum": 0}
+    }
+}`
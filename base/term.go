@@ -0,0 +1,25 @@
+package base
+
+import (
+	"os"
+	"strconv"
+)
+
+// defaultTerminalWidth is the width used when
+// the terminal's width cannot be determined.
+const defaultTerminalWidth = 80
+
+// terminalWidth returns the number of columns available for output,
+// from the COLUMNS environment variable if it is set, otherwise from
+// the terminal attached to stdout.
+func terminalWidth() int {
+	if c, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && c > 0 {
+		return c
+	}
+
+	if w := ttyWidth(); w > 0 {
+		return w
+	}
+
+	return defaultTerminalWidth
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package base
+
+// ttyWidth returns 0, since the terminal size
+// cannot be determined on this platform.
+func ttyWidth() int {
+	return 0
+}
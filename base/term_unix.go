@@ -0,0 +1,27 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package base
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ttyWidth returns the number of columns of the terminal
+// attached to stdout, or 0 if stdout is not a terminal.
+func ttyWidth() int {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(),
+		uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+
+	if errno != 0 {
+		return 0
+	}
+
+	return int(ws.Col)
+}
@@ -0,0 +1,186 @@
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// templateVars are the contents of a vars file for test-template.
+// License fills in the license variables from a local license;
+// the other fields take precedence over it. Copyright is a
+// copyright format, as with --copyright.
+type templateVars struct {
+	License     string   `yaml:"license"`
+	Name        string   `yaml:"name"`
+	Year        string   `yaml:"year"`
+	Email       string   `yaml:"email"`
+	Org         string   `yaml:"org"`
+	Copyright   string   `yaml:"copyright"`
+	Suffix      []string `yaml:"suffix"`
+	SPDXID      string   `yaml:"spdx-id"`
+	LicenseURL  string   `yaml:"license-url"`
+	LicenseName string   `yaml:"license-name"`
+}
+
+// readTemplateVars reads the vars file at p into the values
+// for a template.
+func readTemplateVars(s *settings, p string) (*renderOption, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, newErrReadInputFailed(p)
+	}
+
+	var v templateVars
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, newErrInvalidVars(p, err.Error())
+	}
+
+	o := &renderOption{}
+	if v.License != "" {
+		licenses, err := getLocalList(s)
+		if err != nil {
+			return nil, newErrReadFailed()
+		}
+		l, ok := findLicense(licenses, v.License)
+		if !ok {
+			return nil, newErrCannotFindLicense()
+		}
+		if err := ensureFetched(s, &l); err != nil {
+			return nil, err
+		}
+		l = l.withFullInfo(s)
+		o.SPDXID, o.LicenseURL, o.LicenseName = l.SpdxID, l.HtmlUrl, l.Name
+	}
+
+	if v.Copyright != "" {
+		if o.copyright, err = copyrightFormat("", v.Copyright, p); err != nil {
+			return nil, err
+		}
+	}
+
+	o.Name, o.Year, o.Email, o.Org, o.Suffix = v.Name, v.Year, v.Email, v.Org, v.Suffix
+	o.SPDXID = firstNonEmpty(v.SPDXID, o.SPDXID)
+	o.LicenseURL = firstNonEmpty(v.LicenseURL, o.LicenseURL)
+	o.LicenseName = firstNonEmpty(v.LicenseName, o.LicenseName)
+	return o, nil
+}
+
+// printLineDiff prints the lines that differ between want and got,
+// and returns how many do.
+func printLineDiff(want, got []byte) int {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+
+	n := len(wantLines)
+	if len(gotLines) > n {
+		n = len(gotLines)
+	}
+
+	differ := 0
+	for i := 0; i < n; i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w == g {
+			continue
+		}
+		differ++
+		fmt.Printf("line %d:\n", i+1)
+		if i < len(wantLines) {
+			fmt.Printf("%s- %s\n", indent, w)
+		}
+		if i < len(gotLines) {
+			fmt.Printf("%s+ %s\n", indent, g)
+		}
+	}
+	return differ
+}
+
+// TestTemplate renders a template file with the variables in a vars
+// file, the way licenses are rendered, so that teams that maintain
+// their own templates can test them in CI:
+//
+//	license test-template NOTICE.tmpl --vars vars.yaml --golden NOTICE.golden
+//
+// Variables missing from the vars file are empty, and a template
+// without a year renders without one, so the output does not depend
+// on the date or the user. With --golden, the output is compared to
+// the golden file, and the lines that differ are printed; --update
+// writes the output to the golden file instead. Without --golden, the
+// output is printed.
+func TestTemplate(args []string) error {
+	flagSet := simpleflag.NewFlagSet("test-template")
+	flagSet.Add("vars", []string{"--vars", "-vars"}, false)
+	flagSet.Add("golden", []string{"--golden", "-golden"}, false)
+	flagSet.Add("update", []string{"--update", "-update"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	if len(result.Remaining) != 1 {
+		return newErrExpectedFilename()
+	}
+	p := result.Remaining[0]
+
+	if !pathExists(p) {
+		return newErrReadInputFailed(p)
+	}
+
+	tmpl, err := readTemplateFile(p)
+	if err != nil {
+		return newErrInvalidTemplate(p, err.Error())
+	}
+
+	s := defaultSettings()
+	o := &renderOption{}
+	if varsPath, exists := result.Values["vars"]; exists {
+		if o, err = readTemplateVars(s, varsPath); err != nil {
+			return err
+		}
+	}
+
+	var b bytes.Buffer
+	if err := renderTemplate(tmpl, o, &b); err != nil {
+		return newErrInvalidTemplate(p, err.Error())
+	}
+
+	golden, exists := result.Values["golden"]
+	if !exists {
+		_, err := os.Stdout.Write(b.Bytes())
+		return err
+	}
+
+	if _, update := result.Values["update"]; update {
+		if err := writeFile(s, golden, b.Bytes(), 0644); err != nil {
+			return newErrWriteFileFailed(golden)
+		}
+		return nil
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		return newErrReadInputFailed(golden)
+	}
+
+	if bytes.Equal(want, b.Bytes()) {
+		fmt.Printf("ok %s\n", p)
+		return nil
+	}
+
+	return newErrGoldenMismatch(golden, printLineDiff(want, b.Bytes()))
+}
@@ -0,0 +1,247 @@
+package base
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The undo directory holds a journal of the file changes made by the
+// last command that changed files, and a copy of the previous contents
+// of each file it changed, named by their SHA-256.
+
+var (
+	// undoStarted holds the undo directories in which this process
+	// has replaced the journal of the previous command with its own.
+	undoStarted = make(map[string]bool)
+
+	// undoCopies holds the contents of the files snapshot has seen, by
+	// their SHA-256, until journalChange has been called for each of them.
+	undoCopies = make(map[string]*undoCopy)
+)
+
+// undoCopy is the contents of files that snapshot has seen, and
+// how many of the files have not been passed to journalChange yet.
+type undoCopy struct {
+	contents []byte
+	pending  int
+}
+
+// undoPath returns the path to the undo directory.
+func (s *settings) undoPath() (string, error) {
+	root, err := s.storePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, UndoDirectory), nil
+}
+
+// startUndo returns the path to the undo directory. The first time it is
+// called in a process for a store, it clears the journal of the previous
+// command, so it is only called once a file has actually changed.
+func startUndo(s *settings) (string, error) {
+	p, err := s.undoPath()
+	if err != nil {
+		return "", err
+	}
+
+	if !undoStarted[p] {
+		if err := os.RemoveAll(p); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(p, perm); err != nil {
+			return "", err
+		}
+		undoStarted[p] = true
+	}
+
+	return p, nil
+}
+
+// fileHash returns the SHA-256 of the file at p,
+// or an empty string if it cannot be read.
+func fileHash(p string) string {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+	return sha256Hex(b)
+}
+
+// snapshot returns the SHA-256 of the file at p, or an empty string
+// if it cannot be read, and keeps its contents in case it changes.
+// Call it before changing the file.
+func snapshot(p string) string {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+	sum := sha256Hex(b)
+	c, ok := undoCopies[sum]
+	if !ok {
+		c = &undoCopy{contents: b}
+		undoCopies[sum] = c
+	}
+	c.pending++
+	return sum
+}
+
+// journalChange appends a change to the file at p to the undo journal,
+// along with a copy of the file's previous contents. A file left as it
+// was is not a change, and leaves the journal of the previous command
+// alone.
+func journalChange(s *settings, p, before, after string) {
+	c := undoCopies[before]
+	if c != nil {
+		if c.pending--; c.pending <= 0 {
+			delete(undoCopies, before)
+		}
+	}
+
+	if before == after {
+		return
+	}
+
+	dir, err := startUndo(s)
+	if err == nil && c != nil {
+		if err := ioutil.WriteFile(filepath.Join(dir, before), c.contents, 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "license: failed to keep a copy of %s for undo\n", p)
+		}
+	}
+	if err == nil {
+		var b []byte
+		if b, err = json.Marshal(newAuditEntry(p, before, after)); err == nil {
+			var f *os.File
+			if f, err = os.OpenFile(filepath.Join(dir, JournalFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+				_, err = f.Write(append(b, '\n'))
+				f.Close()
+			}
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "license: failed to record change to %s for undo\n", p)
+	}
+}
+
+// readJournal returns the changes in the undo journal, in order.
+func readJournal(dir string) ([]auditEntry, error) {
+	f, err := os.Open(filepath.Join(dir, JournalFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, newErrReadInputFailed(filepath.Join(dir, JournalFile))
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, newErrDeserializeFailed(s.Bytes())
+		}
+		entries = append(entries, e)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, newErrReadInputFailed(filepath.Join(dir, JournalFile))
+	}
+
+	return entries, nil
+}
+
+// Undo reverts the file changes made by the last command that changed
+// files, such as generating a license or annotating a project. Files
+// changed again since then are left alone, and Undo fails, unless
+// --force is given. Only the last command can be undone.
+func Undo(args []string) error {
+	flagSet := simpleflag.NewFlagSet("undo")
+	flagSet.Add("force", []string{"--force", "-force", "-f"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return newErrParsingArguments()
+	}
+
+	if len(result.BadFlags) > 0 {
+		return newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	_, force := result.Values["force"]
+
+	dir, err := defaultSettings().undoPath()
+	if err != nil {
+		return newErrCannotLocateHomeDir()
+	}
+
+	entries, err := readJournal(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return newErrNothingToUndo()
+	}
+
+	// the state each file was left in by the command
+	final := make(map[string]string)
+	for _, e := range entries {
+		final[e.Path] = e.After
+	}
+
+	if !force {
+		var changed []string
+		for p, after := range final {
+			if fileHash(p) != after {
+				changed = append(changed, p)
+			}
+		}
+		if len(changed) > 0 {
+			return newErrChangedSinceCommand(changed...)
+		}
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+
+		if e.Before == "" {
+			if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+				return newErrRemovePathFailed(e.Path)
+			}
+			auditChange(e.Path, e.After, "")
+			fmt.Printf("%sremoved  %s\n", indent, e.Path)
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(dir, e.Before))
+		if err != nil {
+			return newErrReadInputFailed(filepath.Join(dir, e.Before))
+		}
+
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(e.Path); err == nil {
+			mode = info.Mode()
+		}
+
+		current := fileHash(e.Path)
+		if err := ioutil.WriteFile(e.Path, contents, mode); err != nil {
+			return newErrWriteFileFailed(e.Path)
+		}
+		auditChange(e.Path, current, e.Before)
+		fmt.Printf("%srestored %s\n", indent, e.Path)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return newErrRemovePathFailed(dir)
+	}
+
+	fmt.Printf("undid %d changes made by %q\n", len(entries), entries[0].Command)
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestUndo changes files as a command would, then undoes the changes.
+func TestUndo(t *testing.T) {
+	testcases := []struct {
+		name    string
+		before  map[string]string
+		command func(s *settings, dir string) error
+		edit    bool // change a.txt after the command
+		force   bool
+		after   map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "create",
+			before: map[string]string{},
+			command: func(s *settings, dir string) error {
+				return writeFile(s, filepath.Join(dir, "a.txt"), []byte("new"), 0644)
+			},
+			after: map[string]string{},
+		},
+		{
+			name:   "modify",
+			before: map[string]string{"a.txt": "old"},
+			command: func(s *settings, dir string) error {
+				return writeFile(s, filepath.Join(dir, "a.txt"), []byte("new"), 0644)
+			},
+			after: map[string]string{"a.txt": "old"},
+		},
+		{
+			name:   "modify twice",
+			before: map[string]string{"a.txt": "old"},
+			command: func(s *settings, dir string) error {
+				if err := writeFile(s, filepath.Join(dir, "a.txt"), []byte("new"), 0644); err != nil {
+					return err
+				}
+				return writeFile(s, filepath.Join(dir, "a.txt"), []byte("newer"), 0644)
+			},
+			after: map[string]string{"a.txt": "old"},
+		},
+		{
+			name:   "remove",
+			before: map[string]string{"a.txt": "old", "b.txt": "old"},
+			command: func(s *settings, dir string) error {
+				return removeFile(s, filepath.Join(dir, "b.txt"))
+			},
+			after: map[string]string{"a.txt": "old", "b.txt": "old"},
+		},
+		{
+			name:   "same contents",
+			before: map[string]string{"a.txt": "old"},
+			command: func(s *settings, dir string) error {
+				return writeFile(s, filepath.Join(dir, "a.txt"), []byte("old"), 0644)
+			},
+			after:   map[string]string{"a.txt": "old"},
+			wantErr: true, // nothing to undo
+		},
+		{
+			name:   "changed since",
+			before: map[string]string{"a.txt": "old"},
+			command: func(s *settings, dir string) error {
+				return writeFile(s, filepath.Join(dir, "a.txt"), []byte("new"), 0644)
+			},
+			edit:    true,
+			after:   map[string]string{"a.txt": "edited"},
+			wantErr: true,
+		},
+		{
+			name:   "changed since, forced",
+			before: map[string]string{"a.txt": "old"},
+			command: func(s *settings, dir string) error {
+				return writeFile(s, filepath.Join(dir, "a.txt"), []byte("new"), 0644)
+			},
+			edit:  true,
+			force: true,
+			after: map[string]string{"a.txt": "old"},
+		},
+	}
+
+	defer os.Setenv(LicenseHomeEnvVariable, os.Getenv(LicenseHomeEnvVariable))
+
+	for _, tc := range testcases {
+		root, err := ioutil.TempDir("", "license-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(root)
+
+		dir := filepath.Join(root, "project")
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		for name, contents := range tc.before {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s := &settings{Store: filepath.Join(root, "store")}
+		if err := tc.command(s, dir); err != nil {
+			t.Errorf("%s: command failed: %v", tc.name, err)
+			continue
+		}
+		if len(undoCopies) != 0 {
+			t.Errorf("%s: %d copies kept after the command", tc.name, len(undoCopies))
+		}
+		if tc.edit {
+			if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("edited"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Undo reads the store from the environment
+		os.Setenv(LicenseHomeEnvVariable, s.Store)
+		var args []string
+		if tc.force {
+			args = []string{"--force"}
+		}
+		if err := Undo(args); (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != len(tc.after) {
+			t.Errorf("%s: got %d files, want %d", tc.name, len(files), len(tc.after))
+		}
+		for name, want := range tc.after {
+			if got, err := ioutil.ReadFile(filepath.Join(dir, name)); err != nil || string(got) != want {
+				t.Errorf("%s: got %s %q, %v, want %q", tc.name, name, got, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package base
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/nishanths/simpleflag.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// expressionSplitRx separates the license identifiers of an SPDX
+	// license expression, or of the older "(MIT/Apache-2.0)" form.
+	expressionSplitRx = regexp.MustCompile(`(?i)\s+(?:OR|AND|WITH)\s+|[()/]`)
+
+	// spdxTagRx matches an SPDX-License-Identifier line, leaving
+	// out the end of a block comment if there is one.
+	spdxTagRx = regexp.MustCompile(`SPDX-License-Identifier:\s*(.+?)\s*(?:\*/|-->)?\s*$`)
+)
+
+// licenseSignal is a statement of a project's license found in one place.
+type licenseSignal struct {
+	Source   string   `json:"source"`
+	Licenses []string `json:"licenses"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (s *licenseSignal) expression() string {
+	return strings.Join(s.Licenses, " OR ")
+}
+
+// conflict is a signal that disagrees with the license files.
+type conflict struct {
+	Source   string   `json:"source"`
+	Says     []string `json:"says"`
+	Expected []string `json:"expected"`
+}
+
+func (c *conflict) String() string {
+	return fmt.Sprintf("%s: %s, but the license files say %s", c.Source, strings.Join(c.Says, " OR "), strings.Join(c.Expected, " OR "))
+}
+
+// inference is the license of a project, as inferred from its signals.
+type inference struct {
+	Directory string           `json:"directory"`
+	License   string           `json:"license"`
+	Signals   []*licenseSignal `json:"signals"`
+	Conflicts []*conflict      `json:"conflicts"`
+}
+
+// splitExpression returns the distinct license identifiers in an
+// SPDX license expression, in the order they first appear.
+func splitExpression(expr string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range expressionSplitRx.Split(expr, -1) {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// sameLicenses returns true if a and b have the
+// same license identifiers, ignoring case and order.
+func sameLicenses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, id := range a {
+		if !containsLicense(b, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsLicense returns true if ids has id, ignoring case
+// and deprecated identifiers; see sameSpdxID.
+func containsLicense(ids []string, id string) bool {
+	for _, x := range ids {
+		if sameSpdxID(x, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// licenseFileSignals returns a signal for each license file
+// in dir that can be identified.
+func licenseFileSignals(s *settings, dir string, o *classifyOption) ([]*licenseSignal, error) {
+	candidates := licenseFilenames
+	if matches, err := filepath.Glob(filepath.Join(dir, defaultLicenseFilename+"-*")); err == nil {
+		for _, m := range matches {
+			candidates = append(candidates, filepath.Base(m))
+		}
+	}
+
+	var signals []*licenseSignal
+	for _, name := range candidates {
+		text, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		c, err := classify(s, string(text), o.Strictness)
+		if err != nil {
+			return nil, newErrReadFailed()
+		}
+
+		if c == nil || c.SpdxID == "" || c.Confidence < o.MinConfidence {
+			signals = append(signals, &licenseSignal{Source: name, Detail: "unrecognized license text"})
+			continue
+		}
+		signals = append(signals, &licenseSignal{name, []string{c.SpdxID}, fmt.Sprintf("%.1f%% match", c.Confidence*100)})
+	}
+
+	return signals, nil
+}
+
+// manifestSignal returns the license recorded in the manifest
+// of the project in dir, or nil if there is none.
+func manifestSignal(dir string) *licenseSignal {
+	p := filepath.Join(dir, "Cargo.toml")
+	if contents, err := ioutil.ReadFile(p); err == nil {
+		s := string(contents)
+		if loc := cargoPackageRx.FindStringIndex(s); loc != nil {
+			section := s[loc[1]:]
+			if next := cargoSectionRx.FindStringIndex(section); next != nil {
+				section = section[:next[0]]
+			}
+			if m := cargoLicenseRx.FindStringSubmatch(section); m != nil {
+				return &licenseSignal{Source: "Cargo.toml", Licenses: splitExpression(m[1])}
+			}
+		}
+	}
+
+	p = filepath.Join(dir, "package.json")
+	if contents, err := ioutil.ReadFile(p); err == nil {
+		var manifest struct {
+			License  interface{} `json:"license"`
+			Licenses []struct {
+				Type string `json:"type"`
+			} `json:"licenses"`
+		}
+		if json.Unmarshal(contents, &manifest) != nil {
+			return &licenseSignal{Source: "package.json", Detail: "cannot be parsed"}
+		}
+
+		// "license" is usually an expression, but older
+		// packages use an object or a "licenses" list
+		var ids []string
+		switch v := manifest.License.(type) {
+		case string:
+			ids = splitExpression(v)
+		case map[string]interface{}:
+			if t, ok := v["type"].(string); ok {
+				ids = splitExpression(t)
+			}
+		}
+		for _, l := range manifest.Licenses {
+			ids = append(ids, splitExpression(l.Type)...)
+		}
+		if len(ids) > 0 {
+			return &licenseSignal{Source: "package.json", Licenses: ids}
+		}
+	}
+
+	return nil
+}
+
+// hasLicenseFile returns true if dir has a license file of its own.
+func hasLicenseFile(dir string) bool {
+	for _, name := range licenseFilenames {
+		if pathExists(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, defaultLicenseFilename+"-*"))
+	return len(matches) > 0
+}
+
+// spdxTagSignals returns a signal for each license expression in the
+// SPDX-License-Identifier lines of the project's source files. Directories
+// with a license file of their own are skipped, since they are under a
+// license of their own.
+func spdxTagSignals(root string) ([]*licenseSignal, error) {
+	counts := make(map[string]int)
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return newErrReadInputFailed(p)
+		}
+
+		if info.IsDir() {
+			if p == root {
+				return nil
+			}
+			if strings.HasPrefix(info.Name(), ".") || annotateSkipDirs[info.Name()] || hasLicenseFile(p) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if lineComment(p) == "" {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+
+		// tags are at the top of files
+		scanner := bufio.NewScanner(f)
+		for i := 0; i < 10 && scanner.Scan(); i++ {
+			if m := spdxTagRx.FindSubmatch(scanner.Bytes()); m != nil {
+				counts[string(bytes.TrimSpace(m[1]))]++
+				break
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var exprs []string
+	for expr := range counts {
+		exprs = append(exprs, expr)
+	}
+	sort.Strings(exprs)
+
+	var signals []*licenseSignal
+	for _, expr := range exprs {
+		detail := fmt.Sprintf("%d file", counts[expr])
+		if counts[expr] != 1 {
+			detail += "s"
+		}
+		signals = append(signals, &licenseSignal{"source files", splitExpression(expr), detail})
+	}
+
+	return signals, nil
+}
+
+// readmeSignal returns the licenses that the README in dir names
+// on lines that mention licensing, or nil if there are none.
+func readmeSignal(s *settings, dir string) (*licenseSignal, error) {
+	var name string
+	var contents []byte
+	for _, n := range readmeFilenames {
+		if b, err := ioutil.ReadFile(filepath.Join(dir, n)); err == nil {
+			name, contents = n, b
+			break
+		}
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	licenses, err := getLocalList(s)
+	if err != nil {
+		return nil, newErrReadFailed()
+	}
+
+	// match identifiers and names as whole words, and identifiers
+	// with a space for the hyphen as in "Apache 2.0"
+	type pattern struct {
+		id string
+		rx *regexp.Regexp
+	}
+	var patterns []pattern
+	for _, l := range licenses {
+		if l.SpdxID == "" {
+			continue
+		}
+		// a deprecated identifier, as for the GPL, is also
+		// stated with the identifiers that replace it
+		for _, id := range append([]string{l.SpdxID}, l.replacements()...) {
+			alternatives := []string{regexp.QuoteMeta(id), regexp.QuoteMeta(strings.Replace(id, "-", " ", -1))}
+			if id == l.SpdxID && l.Name != "" {
+				alternatives = append(alternatives, regexp.QuoteMeta(l.Name))
+			}
+			rx := regexp.MustCompile(`(?i)(?:^|[^\w-])(?:` + strings.Join(alternatives, "|") + `)(?:$|[^\w-]|\.\W|\.$)`)
+			patterns = append(patterns, pattern{id, rx})
+		}
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(contents), "\n") {
+		if !strings.Contains(strings.ToLower(line), "licen") {
+			continue
+		}
+		for _, p := range patterns {
+			if !seen[p.id] && p.rx.MatchString(line) {
+				seen[p.id] = true
+				ids = append(ids, p.id)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return &licenseSignal{Source: name, Licenses: ids}, nil
+}
+
+// inferLicense collects the license signals of the project in dir and
+// reports the signals that disagree with its license files.
+func inferLicense(s *settings, dir string, o *classifyOption) (*inference, error) {
+	in := &inference{Directory: dir}
+
+	files, err := licenseFileSignals(s, dir, o)
+	if err != nil {
+		return nil, err
+	}
+	in.Signals = append(in.Signals, files...)
+
+	if signal := manifestSignal(dir); signal != nil {
+		in.Signals = append(in.Signals, signal)
+	}
+
+	tags, err := spdxTagSignals(dir)
+	if err != nil {
+		return nil, err
+	}
+	in.Signals = append(in.Signals, tags...)
+
+	readme, err := readmeSignal(s, dir)
+	if err != nil {
+		return nil, err
+	}
+	if readme != nil {
+		in.Signals = append(in.Signals, readme)
+	}
+
+	// the license files are the authority; without
+	// them, the manifest is
+	var expected []string
+	seen := make(map[string]bool)
+	for _, signal := range files {
+		for _, id := range signal.Licenses {
+			if !seen[id] {
+				seen[id] = true
+				expected = append(expected, id)
+			}
+		}
+	}
+	if len(expected) == 0 {
+		for _, signal := range in.Signals {
+			if len(signal.Licenses) > 0 {
+				expected = signal.Licenses
+				break
+			}
+		}
+	}
+	if len(expected) == 0 {
+		return nil, newErrProjectLicenseNotFound(dir)
+	}
+	in.License = strings.Join(expected, " OR ")
+
+	// the manifest must state the licenses exactly; source files and
+	// the README may name fewer, as for files under one alternative
+	for _, signal := range in.Signals[len(files):] {
+		if len(signal.Licenses) == 0 {
+			continue
+		}
+		if signal.Source == "Cargo.toml" || signal.Source == "package.json" {
+			if !sameLicenses(signal.Licenses, expected) {
+				in.Conflicts = append(in.Conflicts, &conflict{signal.Source, signal.Licenses, expected})
+			}
+			continue
+		}
+		for _, id := range signal.Licenses {
+			if !containsLicense(expected, id) {
+				in.Conflicts = append(in.Conflicts, &conflict{signal.Source, signal.Licenses, expected})
+				break
+			}
+		}
+	}
+
+	return in, nil
+}
+
+func (in *inference) print() {
+	for _, s := range in.Signals {
+		value := s.expression()
+		if value == "" {
+			value = "?"
+		}
+		if s.Detail != "" {
+			value += " (" + s.Detail + ")"
+		}
+		fmt.Printf("%s%-20s%s\n", indent, s.Source, value)
+	}
+
+	fmt.Printf("\nlicense: %s\n", in.License)
+
+	if len(in.Conflicts) > 0 {
+		fmt.Println("\nconflicts:")
+		for _, c := range in.Conflicts {
+			fmt.Println(indent + c.String())
+		}
+	}
+}
+
+// Which infers the license of the project in the current directory, or
+// the directory named in the arguments, by combining its license files,
+// the license field of its manifest, the SPDX-License-Identifier lines of
+// its source files, and the statements in its README. Signals that
+// disagree with the license files are reported as conflicts, and
+// Which returns an error if there are any.
+//
+// With --check, Which returns an error unless the project's license is
+// the given license expression. With --quiet, nothing at all is printed,
+// so that the result is conveyed only by the exit code.
+func Which(args []string) error {
+	quiet, err := whichCommand(args)
+	if quiet {
+		return silence(err)
+	}
+	return err
+}
+
+func whichCommand(args []string) (quiet bool, err error) {
+	flagSet := simpleflag.NewFlagSet("which")
+	flagSet.Add("json", []string{"--json", "-json"}, true)
+	flagSet.Add("min-confidence", []string{"--min-confidence", "-min-confidence"}, false)
+	flagSet.Add("strictness", []string{"--strictness", "-strictness"}, false)
+	flagSet.Add("check", []string{"--check", "-check"}, false)
+	flagSet.Add("quiet", []string{"--quiet", "-quiet", "-q"}, true)
+	result, err := flagSet.Parse(args)
+
+	if err != nil {
+		return false, newErrParsingArguments()
+	}
+
+	_, quiet = result.Values["quiet"]
+
+	if len(result.BadFlags) > 0 {
+		return quiet, newErrBadFlagSyntax(result.BadFlags[0])
+	}
+
+	dir := "."
+	switch len(result.Remaining) {
+	case 0:
+	case 1:
+		dir = result.Remaining[0]
+	default:
+		return quiet, newErrExpectedDirectory()
+	}
+
+	o, err := parseClassifyOption(result.Values)
+	if err != nil {
+		return quiet, err
+	}
+
+	in, err := inferLicense(defaultSettings(), dir, o)
+	if err != nil {
+		return quiet, err
+	}
+
+	if !quiet {
+		if _, exists := result.Values["json"]; exists {
+			if in.Conflicts == nil {
+				in.Conflicts = []*conflict{}
+			}
+			b, err := json.MarshalIndent(in, "", indent)
+			if err != nil {
+				return quiet, newErrSerializeFailed(in)
+			}
+			fmt.Println(string(b))
+		} else {
+			in.print()
+		}
+	}
+
+	if len(in.Conflicts) > 0 {
+		return quiet, newErrLicenseConflicts(len(in.Conflicts))
+	}
+
+	if want, exists := result.Values["check"]; exists && !sameLicenses(splitExpression(want), splitExpression(in.License)) {
+		return quiet, newErrCheckFailed(want, in.License)
+	}
+
+	return quiet, nil
+}
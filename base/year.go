@@ -0,0 +1,95 @@
+package base
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Year policies decide the year on a license when no year is given.
+const (
+	// yearPolicyCurrent uses the current year, the default.
+	yearPolicyCurrent = "current"
+	// yearPolicyFirst uses the year the project was first published.
+	yearPolicyFirst = "first"
+	// yearPolicyRange uses the years from the first publication
+	// to the current year, such as 2015-2024.
+	yearPolicyRange = "range"
+	// yearPolicyNone leaves the year out.
+	yearPolicyNone = "none"
+)
+
+// yearPolicy returns the year policy for the license with the key:
+// policy, if not empty, then the license's year-policy setting, then
+// the environment, then the current year. source names where policy
+// came from, for errors.
+func yearPolicy(key, policy, source string) (string, error) {
+	if policy == "" && key != "" {
+		policy, source = getLicenseSetting(key, "year-policy"), licenseSettingSection+"."+key+".year-policy"
+	}
+	if policy == "" {
+		policy, source = getYearPolicy(), YearPolicyEnvVariable
+	}
+
+	switch policy {
+	case "":
+		return yearPolicyCurrent, nil
+	case yearPolicyCurrent, yearPolicyFirst, yearPolicyRange, yearPolicyNone:
+		return policy, nil
+	}
+	return "", newErrInvalidArgument(source, policy)
+}
+
+// firstYear returns the first year in the copyright notice of the
+// first of the files that has one, or an empty string if none does.
+func firstYear(paths []string) string {
+	for _, p := range paths {
+		text, err := ioutil.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		if year, _, ok := parseCopyrightNotice(string(text)); ok {
+			return year[:4]
+		}
+	}
+	return ""
+}
+
+// licenseFilesIn returns the paths of the license files in dir.
+func licenseFilesIn(dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && licenseFileRx.MatchString(e.Name()) {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	return paths
+}
+
+// policyYear returns the year to put on a license under the policy.
+// The year of first publication is taken from the copyright notice
+// of the first of the existing license files that has one, and is
+// the current year for a project that has none yet.
+func policyYear(policy string, existing []string) string {
+	current := strconv.Itoa(time.Now().Year())
+
+	switch policy {
+	case yearPolicyNone:
+		return ""
+	case yearPolicyFirst:
+		if first := firstYear(existing); first != "" {
+			return first
+		}
+	case yearPolicyRange:
+		if first := firstYear(existing); first != "" && first < current {
+			return first + "-" + current
+		}
+	}
+	return current
+}
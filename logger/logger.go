@@ -1,6 +1,9 @@
 package logger
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+)
 
 type logLevel struct {
 	Verbose, Quiet bool
@@ -74,3 +77,59 @@ func VerbosePrintln(args ...interface{}) {
 		fmt.Println(args...)
 	}
 }
+
+// IsQuiet returns true if quiet mode is on.
+func IsQuiet() bool {
+	return globalLogLevel.Quiet
+}
+
+// Logger prints every message, including verbose ones, to a writer of
+// its own, so that the messages of one call can go somewhere without
+// changing where other messages go. A nil *Logger prints like the
+// package functions.
+type Logger struct {
+	w io.Writer
+}
+
+// New returns a Logger that prints to w.
+func New(w io.Writer) *Logger {
+	return &Logger{w}
+}
+
+// Printf prints like fmt.Printf
+func (l *Logger) Printf(format string, args ...interface{}) {
+	if l == nil {
+		Printf(format, args...)
+		return
+	}
+	fmt.Fprintf(l.w, format, args...)
+}
+
+// Println prints like fmt.Println
+func (l *Logger) Println(args ...interface{}) {
+	if l == nil {
+		Println(args...)
+		return
+	}
+	fmt.Fprintln(l.w, args...)
+}
+
+// VerbosePrintf prints like fmt.Printf, and for a nil *Logger
+// only when verbose logging is on and quiet mode is off
+func (l *Logger) VerbosePrintf(format string, args ...interface{}) {
+	if l == nil {
+		VerbosePrintf(format, args...)
+		return
+	}
+	fmt.Fprintf(l.w, format, args...)
+}
+
+// VerbosePrintln prints like fmt.Println, and for a nil *Logger
+// only when verbose logging is on and quiet mode is off
+func (l *Logger) VerbosePrintln(args ...interface{}) {
+	if l == nil {
+		VerbosePrintln(args...)
+		return
+	}
+	fmt.Fprintln(l.w, args...)
+}
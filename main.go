@@ -2,50 +2,84 @@ package main
 
 import (
 	"fmt"
-	"github.com/mitchellh/go-homedir"
 	"github.com/nishanths/license/base"
+	"io/ioutil"
 	"os"
-	"path"
 	"sync"
 	"time"
 )
 
-// pathExists returns true if the path exists.
-func pathExists(p string) bool {
-	if _, err := os.Stat(p); os.IsNotExist(err) {
-		return false
+// parseGlobalOptions consumes the options that apply to every
+// command, "--timeout <duration>" and "-C <dir>", from the start
+// of args and returns the remaining args.
+func parseGlobalOptions(args []string) ([]string, error) {
+	for len(args) > 0 {
+		switch args[0] {
+		case "--timeout", "-timeout":
+			if len(args) < 2 {
+				return nil, fmt.Errorf("license: expected a duration after %q, such as 30s", args[0])
+			}
+			d, err := time.ParseDuration(args[1])
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("license: invalid duration %q for %q, use a value such as 30s or 2m", args[1], args[0])
+			}
+			base.SetTimeout(d)
+
+		case "-C", "--chdir", "-chdir":
+			if len(args) < 2 {
+				return nil, fmt.Errorf("license: expected a directory after %q", args[0])
+			}
+			if err := os.Chdir(args[1]); err != nil {
+				return nil, fmt.Errorf("license: cannot change to directory %q", args[1])
+			}
+
+		default:
+			return args, nil
+		}
+		args = args[2:]
 	}
-	return true
+	return args, nil
 }
 
-// main returns exit code 0 on success
-// and exit code 1 on error.
+// main returns exit code 0 on success,
+// exit code 130 if interrupted during an update,
+// exit code 124 if a command ran past its timeout,
+// and exit code 1 on other errors.
 // Errors, if any, are sent to stderr.
 // Other program output is sent to stdout.
 func main() {
-	args := os.Args[1:]
+	args, err := parseGlobalOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// bring local licenses from older versions up to date;
+	// commands still work from the older layout if this fails
+	if err := base.Migrate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
 	var wg sync.WaitGroup
-	var mainErr error
+	var mainErr, bootstrapErr error
 
 	// * Check existence of license data directory
 	// and start making it if it is not present.
 	// * Also, another time we update is around once every 20 runs
 	// so that the licenses list is up to date.
-	// * If we cannot find the home directory, we silently ignore the issue
-	// for now; the specific command function will return an error when called.
-	if home, err := homedir.Dir(); err == nil {
-		updateRequired := (time.Now().Unix() % 20) == 0
-		bootstrapRequired := !pathExists(path.Join(home, base.LicenseDirectory, base.DataDirectory))
-		repetitiveCommand := len(args) > 0 && (args[0] == "update" || args[0] == "bootstrap")
-
-		if (updateRequired || bootstrapRequired) && !(repetitiveCommand) {
-			wg.Add(1)
-
-			go func() {
-				defer wg.Done()
-				base.Bootstrap([]string{"--quiet"})
-			}()
-		}
+	// * Without a home directory, the licenses are kept in a fallback
+	// directory, which is made the same way.
+	updateRequired := (time.Now().Unix() % 20) == 0
+	bootstrapRequired := !base.HasLocalData()
+	repetitiveCommand := len(args) > 0 && (args[0] == "update" || args[0] == "bootstrap" || args[0] == "prefetch")
+
+	if (updateRequired || bootstrapRequired) && !(repetitiveCommand) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			_, bootstrapErr = base.Bootstrap(base.WithLogger(ioutil.Discard))
+		}()
 	}
 
 	if len(args) < 1 {
@@ -61,27 +95,88 @@ func main() {
 			mainErr = base.Version()
 
 		case "update", "bootstrap":
-			mainErr = base.Bootstrap(args[1:])
+			mainErr = base.UpdateCommand(args[1:])
 
 		case "ls-remote", "list-remote":
-			mainErr = base.ListRemote()
+			mainErr = base.ListRemote(args[1:])
 
 		case "ls", "list":
 			wg.Wait()
-			mainErr = base.ListLocal()
+			mainErr = base.ListLocal(args[1:])
+
+		case "classify":
+			wg.Wait()
+			mainErr = base.Classify(args[1:])
+
+		case "relicense":
+			wg.Wait()
+			mainErr = base.Relicense(args[1:])
+
+		case "licenses-of":
+			wg.Wait()
+			mainErr = base.LicensesOf(args[1:])
+
+		case "import-tree":
+			wg.Wait()
+			mainErr = base.ImportTree(args[1:])
+
+		case "annotate":
+			wg.Wait()
+			mainErr = base.Annotate(args[1:])
+
+		case "which":
+			wg.Wait()
+			mainErr = base.Which(args[1:])
+
+		case "apply":
+			wg.Wait()
+			mainErr = base.Apply(args[1:])
+
+		case "prefetch":
+			mainErr = base.Prefetch(args[1:])
+
+		case "test-template":
+			wg.Wait()
+			mainErr = base.TestTemplate(args[1:])
+
+		case "explain":
+			wg.Wait()
+			mainErr = base.Explain(args[1:])
+
+		case "schema":
+			mainErr = base.Schema(args[1:])
+
+		case "sources":
+			mainErr = base.Sources(args[1:])
+
+		case "doctor":
+			wg.Wait()
+			mainErr = base.Doctor(args[1:])
+
+		case "changes":
+			mainErr = base.Changes(args[1:])
+
+		case "undo":
+			wg.Wait()
+			mainErr = base.Undo(args[1:])
 
 		default:
 			wg.Wait()
-			mainErr = base.Generate(args)
+			mainErr = base.GenerateCommand(args)
 		}
 	}
 
 	wg.Wait()
 
-	if mainErr != nil {
+	// an interrupted background update means the user
+	// wants to stop, whatever the command's outcome
+	if base.IsInterrupted(bootstrapErr) {
+		mainErr = bootstrapErr
+	}
+
+	if mainErr != nil && !base.IsSilent(mainErr) {
 		fmt.Fprintln(os.Stderr, mainErr)
-		os.Exit(1)
 	}
 
-	os.Exit(0)
+	os.Exit(base.ExitCode(mainErr))
 }